@@ -0,0 +1,131 @@
+package dbshiftcore
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// getNextBatch returns the batch for a new run, or zero when the database does not provide history.
+func (c *cmd) getNextBatch() (uint, error) {
+	historyDb, ok := c.db.(iHistoryDatabase)
+	if !ok {
+		return 0, nil
+	}
+
+	history, err := historyDb.GetHistory()
+	if err != nil {
+		return 0, err
+	}
+
+	var lastBatch uint
+	for _, m := range history {
+		if m.Batch > lastBatch {
+			lastBatch = m.Batch
+		}
+	}
+
+	return lastBatch + 1, nil
+}
+
+// rollback downgrades the migrations executed by the most recent batches.
+func (c *cmd) rollback(batches int) error {
+	migrationList, err := c.getRollbackPlan(batches, false)
+	if err != nil {
+		return err
+	}
+
+	return c.execMigrations(migrationList)
+}
+
+// getRollbackPlan returns the downgrading migrations of the upgrades still applied by the most recent batches.
+func (c *cmd) getRollbackPlan(batches int, isForced bool) ([]Migration, error) {
+	historyDb, ok := c.db.(iHistoryDatabase)
+	if !ok {
+		return nil, errors.New("rollback is not supported: database implementation does not provide history")
+	}
+
+	history, err := historyDb.GetHistory()
+	if err != nil {
+		return nil, err
+	}
+
+	// Versions of the selected batches
+	versions := getBatchVersions(getAppliedUpgrades(history), batches)
+	if len(versions) == 0 {
+		return nil, nil
+	}
+
+	sort.Strings(versions)
+	migrationList, err := c.getDowngradePlan(versions[0], isForced)
+	if err != nil {
+		return nil, err
+	}
+
+	// The plan must match exactly the migrations of the batches
+	for _, m := range migrationList {
+		if !containsString(versions, m.Version) {
+			return nil, fmt.Errorf("migration %s does not belong to the last %d batches", m.Name, batches)
+		}
+	}
+
+	return migrationList, nil
+}
+
+// getAppliedUpgrades replays the history and returns the upgrades still applied.
+func getAppliedUpgrades(history []Migration) map[string]Migration {
+	applied := map[string]Migration{}
+	for _, m := range history {
+		if m.Type == migrationTypeUpgrade {
+			applied[m.Version] = m
+		} else {
+			delete(applied, m.Version)
+		}
+	}
+	return applied
+}
+
+// getBatchVersions returns the versions executed by the given amount of most recent batches.
+func getBatchVersions(applied map[string]Migration, batches int) []string {
+	var batchList []uint
+	for _, m := range applied {
+		if m.Batch != 0 && !containsBatch(batchList, m.Batch) {
+			batchList = append(batchList, m.Batch)
+		}
+	}
+
+	// Most recent first
+	sort.Slice(batchList, func(i, j int) bool {
+		return batchList[i] > batchList[j]
+	})
+	if len(batchList) > batches {
+		batchList = batchList[:batches]
+	}
+
+	var versions []string
+	for _, m := range applied {
+		if containsBatch(batchList, m.Batch) {
+			versions = append(versions, m.Version)
+		}
+	}
+
+	return versions
+}
+
+func containsBatch(batchList []uint, batch uint) bool {
+	for _, b := range batchList {
+		if b == batch {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
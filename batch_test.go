@@ -0,0 +1,95 @@
+package dbshiftcore
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"testing"
+)
+
+func TestCmd_Rollback(t *testing.T) {
+	err := setConfigurationWithCustomOptions("false", "false", "false")
+	assert.Nil(t, err, "expected nil error on set configuration with custom options")
+	defer unsetConfiguration(t, "DBSHIFT_DUMMY_ROLLBACK_STATUS")
+
+	db := &dummyDbImplementation{envStatus: "DBSHIFT_DUMMY_ROLLBACK_STATUS"}
+	batchCmd, err := NewCmd(db)
+	assert.Nil(t, err, "expected nil error")
+
+	migrationsPath := os.Getenv(envPathMigrations)
+
+	// First batch
+	writeMigrationFiles(t, migrationsPath, "20200101000000", "first")
+	writeMigrationFiles(t, migrationsPath, "20200102000000", "second")
+	assert.Nil(t, batchCmd.upgrade(""))
+
+	// Second batch
+	writeMigrationFiles(t, migrationsPath, "20200103000000", "third")
+	writeMigrationFiles(t, migrationsPath, "20200104000000", "fourth")
+	assert.Nil(t, batchCmd.upgrade(""))
+
+	for i, m := range db.history {
+		assert.Equal(t, uint(i/2+1), m.Batch, "expected batch of migration %s", m.Name)
+	}
+
+	migrationList, err := batchCmd.getRollbackPlan(1, false)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"20200104000000", "20200103000000"}, getVersions(migrationList))
+
+	migrationList, err = batchCmd.getRollbackPlan(2, false)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"20200104000000", "20200103000000", "20200102000000", "20200101000000"}, getVersions(migrationList))
+
+	// Rollback of the second batch, then of the first one
+	assert.Nil(t, batchCmd.rollback(1))
+	migrationList, err = batchCmd.getRollbackPlan(1, false)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"20200102000000", "20200101000000"}, getVersions(migrationList))
+
+	assert.Nil(t, batchCmd.rollback(1))
+	migrationList, err = batchCmd.getRollbackPlan(1, false)
+	assert.Nil(t, err)
+	assert.Empty(t, migrationList)
+}
+
+func TestCmd_Rollback_NoHistory(t *testing.T) {
+	batchCmd := &cmd{db: noHistoryDbImplementation{new(dummyDbImplementation)}}
+
+	_, err := batchCmd.getRollbackPlan(1, false)
+	assert.NotNil(t, err, "expected error without history capability")
+
+	batch, err := batchCmd.getNextBatch()
+	assert.Nil(t, err)
+	assert.Equal(t, uint(0), batch)
+}
+
+func TestGetAppliedUpgrades(t *testing.T) {
+	history := []Migration{
+		{Version: "1", Type: migrationTypeUpgrade, Batch: 1},
+		{Version: "2", Type: migrationTypeUpgrade, Batch: 1},
+		{Version: "2", Type: migrationTypeDowngrade, Batch: 2},
+		{Version: "2", Type: migrationTypeUpgrade, Batch: 3},
+	}
+
+	applied := getAppliedUpgrades(history)
+	assert.Equal(t, 2, len(applied))
+	assert.Equal(t, uint(1), applied["1"].Batch)
+	assert.Equal(t, uint(3), applied["2"].Batch)
+
+	assert.Equal(t, []string{"2"}, getBatchVersions(applied, 1))
+	assert.Equal(t, 2, len(getBatchVersions(applied, 5)))
+}
+
+// Helpers
+
+// noHistoryDbImplementation hides the optional capabilities of the wrapped database
+type noHistoryDbImplementation struct {
+	iDatabase
+}
+
+func getVersions(migrationList []Migration) []string {
+	var versions []string
+	for _, m := range migrationList {
+		versions = append(versions, m.Version)
+	}
+	return versions
+}
@@ -0,0 +1,411 @@
+package dbshiftcore
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/abiosoft/ishell"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+const (
+	flagTo     = "to"
+	flagSteps  = "steps"
+	flagDryRun = "dry-run"
+)
+
+// command is a command available both in the interactive shell and from the command line.
+// Args describes the positional arguments, at most maxArgs of them are accepted.
+type command struct {
+	name    string
+	args    string
+	maxArgs int
+	help    string
+	flags   []string
+	run     func(ctx *commandContext) error
+}
+
+// commandContext is a parsed invocation of a command.
+type commandContext struct {
+	args     []string
+	options  commandOptions
+	readLine func() string
+}
+
+// commandOptions holds the typed flags of a command invocation.
+type commandOptions struct {
+	to       string
+	steps    int
+	dryRun   bool
+	format   string
+	yes      bool
+	force    bool
+	verbose  bool
+	phase    string
+	template string
+	from     string
+	down     bool
+	output   string
+}
+
+// commandFlag describes a flag shared by the commands accepting it.
+// Value is the placeholder of the flag value, empty for boolean flags.
+type commandFlag struct {
+	value  string
+	usage  string
+	define func(fs *flag.FlagSet, o *commandOptions, name string, usage string)
+}
+
+var commandFlags = map[string]commandFlag{
+	flagTo: {
+		value: "version",
+		usage: "inclusive version to reach",
+		define: func(fs *flag.FlagSet, o *commandOptions, name string, usage string) {
+			fs.StringVar(&o.to, name, "", usage)
+		},
+	},
+	flagSteps: {
+		value: "n",
+		usage: "amount of migrations to execute",
+		define: func(fs *flag.FlagSet, o *commandOptions, name string, usage string) {
+			fs.IntVar(&o.steps, name, 0, usage)
+		},
+	},
+	flagDryRun: {
+		usage: "print the migrations without executing them",
+		define: func(fs *flag.FlagSet, o *commandOptions, name string, usage string) {
+			fs.BoolVar(&o.dryRun, name, false, usage)
+		},
+	},
+	flagFormat: {
+		value: formatText + "|" + formatJSON,
+		usage: "output format",
+		define: func(fs *flag.FlagSet, o *commandOptions, name string, usage string) {
+			fs.Var(&choiceValue{value: &o.format, choices: []string{formatText, formatJSON}}, name, usage)
+		},
+	},
+	flagYes: {
+		usage: "skip the confirmation",
+		define: func(fs *flag.FlagSet, o *commandOptions, name string, usage string) {
+			fs.BoolVar(&o.yes, name, false, usage)
+		},
+	},
+	flagForce: {
+		usage: "cross irreversible migrations",
+		define: func(fs *flag.FlagSet, o *commandOptions, name string, usage string) {
+			fs.BoolVar(&o.force, name, false, usage)
+		},
+	},
+	flagVerbose: {
+		usage: "list the ignored files as well",
+		define: func(fs *flag.FlagSet, o *commandOptions, name string, usage string) {
+			fs.BoolVar(&o.verbose, name, false, usage)
+		},
+	},
+	flagPhase: {
+		value: phasePre + "|" + phasePost,
+		usage: "deployment phase of the migrations to execute",
+		define: func(fs *flag.FlagSet, o *commandOptions, name string, usage string) {
+			fs.Var(&choiceValue{value: &o.phase, choices: []string{phasePre, phasePost}}, name, usage)
+		},
+	},
+	flagTemplate: {
+		value: "name",
+		usage: "template of the migration files",
+		define: func(fs *flag.FlagSet, o *commandOptions, name string, usage string) {
+			fs.StringVar(&o.template, name, "", usage)
+		},
+	},
+	flagFrom: {
+		value: "file",
+		usage: "file of the baseline, otherwise dumped from the database",
+		define: func(fs *flag.FlagSet, o *commandOptions, name string, usage string) {
+			fs.StringVar(&o.from, name, "", usage)
+		},
+	},
+	flagDown: {
+		usage: "export the downgrades instead of the upgrades",
+		define: func(fs *flag.FlagSet, o *commandOptions, name string, usage string) {
+			fs.BoolVar(&o.down, name, false, usage)
+		},
+	},
+	flagOutput: {
+		value: "file",
+		usage: "file of the script, otherwise the standard output",
+		define: func(fs *flag.FlagSet, o *commandOptions, name string, usage string) {
+			fs.StringVar(&o.output, name, "", usage)
+		},
+	},
+}
+
+// choiceValue is a flag value restricted to a set of choices.
+type choiceValue struct {
+	value   *string
+	choices []string
+}
+
+func (v *choiceValue) String() string {
+	if v.value == nil {
+		return ""
+	}
+	return *v.value
+}
+
+func (v *choiceValue) Set(value string) error {
+	for _, choice := range v.choices {
+		if value == choice {
+			*v.value = value
+			return nil
+		}
+	}
+	return fmt.Errorf("expected %s", strings.Join(v.choices, " or "))
+}
+
+// getUsage returns the synopsis of the command, e.g. "render <version>".
+func (cmd command) getUsage() string {
+	usage := []string{cmd.name}
+	for _, name := range cmd.flags {
+		if f := commandFlags[name]; f.value != "" {
+			usage = append(usage, fmt.Sprintf("[--%s %s]", name, f.value))
+		} else {
+			usage = append(usage, fmt.Sprintf("[--%s]", name))
+		}
+	}
+	if cmd.args != "" {
+		usage = append(usage, cmd.args)
+	}
+	return strings.Join(usage, " ")
+}
+
+// getHelp returns the synopsis, the description and the flags of the command.
+func (cmd command) getHelp() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Usage: dbshift %s\n\n%s\n", cmd.getUsage(), cmd.help)
+
+	if len(cmd.flags) > 0 {
+		b.WriteString("\nFlags:\n")
+		w := tabwriter.NewWriter(&b, 0, 4, 2, ' ', 0)
+		for _, name := range cmd.flags {
+			f := commandFlags[name]
+			if f.value != "" {
+				fmt.Fprintf(w, "  --%s %s\t%s\n", name, f.value, f.usage)
+			} else {
+				fmt.Fprintf(w, "  --%s\t%s\n", name, f.usage)
+			}
+		}
+		w.Flush()
+	}
+
+	return b.String()
+}
+
+// parse parses the arguments of the command, flags and positional arguments can be interleaved.
+// It returns a nil context when the help of the command is requested.
+func (cmd command) parse(args []string, readLine func() string) (*commandContext, error) {
+	ctx := &commandContext{readLine: readLine}
+
+	fs := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	for _, name := range cmd.flags {
+		f := commandFlags[name]
+		f.define(fs, &ctx.options, name, f.usage)
+	}
+
+	for {
+		if err := fs.Parse(args); err == flag.ErrHelp {
+			return nil, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("%s: usage %s", err, cmd.getUsage())
+		}
+
+		args = fs.Args()
+		if len(args) == 0 {
+			break
+		}
+		ctx.args = append(ctx.args, args[0])
+		args = args[1:]
+	}
+
+	if len(ctx.args) > cmd.maxArgs {
+		return nil, fmt.Errorf("too many arguments: usage %s", cmd.getUsage())
+	}
+	if ctx.options.steps < 0 {
+		return nil, fmt.Errorf("invalid amount of steps: %d", ctx.options.steps)
+	}
+
+	// The output of an execution is not machine-readable, only the plan of a dry run is
+	if ctx.options.format != "" && !ctx.options.dryRun && containsString(cmd.flags, flagDryRun) {
+		return nil, fmt.Errorf("--%s requires --%s: usage %s", flagFormat, flagDryRun, cmd.getUsage())
+	}
+
+	return ctx, nil
+}
+
+// getVersion returns the inclusive version to reach, given either as argument or by --to.
+func (ctx *commandContext) getVersion() (string, error) {
+	if len(ctx.args) == 0 {
+		return ctx.options.to, nil
+	}
+	if ctx.options.to != "" && ctx.options.to != ctx.args[0] {
+		return "", fmt.Errorf("version given both as argument %s and by --%s %s", ctx.args[0], flagTo, ctx.options.to)
+	}
+	return ctx.args[0], nil
+}
+
+// getStepsVersion returns the version of the last migration executed by the given amount of steps of the plan,
+// or an empty string when the plan does not have more steps.
+func getStepsVersion(t migrationType, migrationList []Migration, steps int) string {
+	if steps == 0 || steps >= len(migrationList) {
+		return ""
+	}
+
+	versionList := make([]string, 0, len(migrationList))
+	for _, m := range migrationList {
+		versionList = append(versionList, m.Version)
+	}
+
+	if t == migrationTypeDowngrade {
+		sort.Sort(sort.Reverse(sort.StringSlice(versionList)))
+	} else {
+		sort.Strings(versionList)
+	}
+
+	return versionList[steps-1]
+}
+
+// printPlan prints the migrations that would be executed by the action.
+func (c *cmd) printPlan(action string, migrationList []Migration, format string) error {
+	if format != formatJSON {
+		if len(migrationList) == 0 {
+			c.printSuccess("Nothing to %s", action)
+			return nil
+		}
+		c.printMigrationList(action, migrationList)
+		return nil
+	}
+
+	type planEntry struct {
+		Version string `json:"version"`
+		Name    string `json:"name"`
+		Type    string `json:"type"`
+	}
+
+	entries := make([]planEntry, 0, len(migrationList))
+	for _, m := range migrationList {
+		entries = append(entries, planEntry{Version: m.Version, Name: m.Name, Type: m.Type.String()})
+	}
+
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	c.printf("%s\n", string(data))
+
+	return nil
+}
+
+// getCommand returns the command with the given name.
+func (c *cmd) getCommand(name string) (command, bool) {
+	for _, cmd := range c.getCommands() {
+		if cmd.name == name {
+			return cmd, true
+		}
+	}
+	return command{}, false
+}
+
+// runCommand parses the arguments and runs the command, or prints its help when requested.
+func (c *cmd) runCommand(cmd command, args []string, readLine func() string) error {
+	ctx, err := cmd.parse(args, readLine)
+	if err != nil {
+		return err
+	}
+	if ctx == nil {
+		c.printf("%s", cmd.getHelp())
+		return nil
+	}
+	return cmd.run(ctx)
+}
+
+// executeCommand runs the command given by the arguments in the non-interactive mode and returns the exit code.
+func (c *cmd) executeCommand(args []string) int {
+	if args[0] == "help" {
+		if len(args) == 1 {
+			c.printUsage()
+			return 0
+		}
+		cmd, ok := c.getCommand(args[1])
+		if !ok {
+			c.printFailure("unknown command %s", args[1])
+			return 1
+		}
+		c.printf("%s", cmd.getHelp())
+		return 0
+	}
+
+	cmd, ok := c.getCommand(args[0])
+	if !ok {
+		c.printFailure("unknown command %s: run dbshift help for the list of commands", args[0])
+		return 1
+	}
+
+	reader := bufio.NewReader(c.in)
+	readLine := func() string {
+		line, _ := reader.ReadString('\n')
+		return line
+	}
+
+	if err := c.runCommand(cmd, args[1:], readLine); err != nil {
+		c.printFailure(err.Error())
+		if c.exitCode == 0 {
+			return 1
+		}
+	}
+
+	return c.exitCode
+}
+
+// printUsage prints the commands and the configuration flags of the non-interactive mode.
+func (c *cmd) printUsage() {
+	c.printf("Usage: dbshift [configuration flags] <command> [flags] [arguments]\n\nCommands:\n")
+	for _, cmd := range c.getCommands() {
+		c.printf("  %s\n", cmd.getUsage())
+	}
+
+	c.printf("\nConfiguration flags:\n")
+	w := tabwriter.NewWriter(c.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "  --%s file\t%s\n", flagConfigurationFile, "configuration file")
+	for _, setting := range configurationSettings {
+		if setting.isBool {
+			fmt.Fprintf(w, "  --%s\t%s\n", setting.flag, setting.usage)
+		} else {
+			fmt.Fprintf(w, "  --%s value\t%s\n", setting.flag, setting.usage)
+		}
+	}
+	w.Flush()
+
+	c.printf("\nRun dbshift help <command> for the flags of a command, or dbshift without arguments for the interactive mode.\n")
+}
+
+// getShellCommands returns the commands of the interactive mode.
+func (c *cmd) getShellCommands() []*ishell.Cmd {
+	var shellCommands []*ishell.Cmd
+	for _, cmd := range c.getCommands() {
+		cmd := cmd
+		shellCommands = append(shellCommands, &ishell.Cmd{
+			Name:     cmd.name,
+			Help:     cmd.getUsage(),
+			LongHelp: cmd.getHelp(),
+			Func: func(ctx *ishell.Context) {
+				if err := c.runCommand(cmd, ctx.Args, ctx.ReadLine); err != nil {
+					c.printFailure(err.Error())
+				}
+			},
+		})
+	}
+	return shellCommands
+}
@@ -0,0 +1,164 @@
+package dbshiftcore
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestCommand_Parse(t *testing.T) {
+	err := setConfigurationWithCustomOptions("false", "false", "false")
+	assert.Nil(t, err, "expected nil error on set configuration with custom options")
+	defer unsetConfiguration(t, "DBSHIFT_DUMMY_CLI_STATUS")
+
+	cliCmd, err := NewCmd(&dummyDbImplementation{envStatus: "DBSHIFT_DUMMY_CLI_STATUS"})
+	assert.Nil(t, err, "expected nil error")
+
+	upgrade, ok := cliCmd.getCommand("upgrade")
+	assert.True(t, ok)
+
+	// Flags and arguments can be interleaved
+	ctx, err := upgrade.parse([]string{"20190926154408", "--yes", "--phase", "pre", "--steps=2"}, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"20190926154408"}, ctx.args)
+	assert.True(t, ctx.options.yes)
+	assert.Equal(t, phasePre, ctx.options.phase)
+	assert.Equal(t, 2, ctx.options.steps)
+
+	version, err := ctx.getVersion()
+	assert.Nil(t, err)
+	assert.Equal(t, "20190926154408", version)
+
+	ctx, err = upgrade.parse([]string{"--to", "20190926154408", "--dry-run", "--format", "json"}, nil)
+	assert.Nil(t, err)
+	assert.Empty(t, ctx.args)
+	assert.True(t, ctx.options.dryRun)
+	assert.Equal(t, formatJSON, ctx.options.format)
+
+	version, err = ctx.getVersion()
+	assert.Nil(t, err)
+	assert.Equal(t, "20190926154408", version)
+
+	ctx, err = upgrade.parse([]string{"--to", "20190926154408", "20200101000000"}, nil)
+	assert.Nil(t, err)
+	_, err = ctx.getVersion()
+	assert.NotNil(t, err, "expected error on version given twice")
+
+	// Help
+	ctx, err = upgrade.parse([]string{"--help"}, nil)
+	assert.Nil(t, err)
+	assert.Nil(t, ctx, "expected nil context on help")
+	assert.Contains(t, upgrade.getHelp(), "Usage: dbshift upgrade [--yes] [--to version] [--steps n] [--phase pre|post] [--dry-run] [--format text|json] [toInclusiveVersion]")
+	assert.Contains(t, upgrade.getHelp(), "--steps n")
+
+	// Bad invocations
+	for _, args := range [][]string{
+		{"--phase"},
+		{"--phase", "middle"},
+		{"--format", "xml"},
+		{"--format", "json"},
+		{"--steps", "many"},
+		{"--steps", "-1"},
+		{"--unknown"},
+		{"20190926154408", "20200101000000"},
+	} {
+		_, err = upgrade.parse(args, nil)
+		assert.NotNil(t, err, "expected error on %v", args)
+	}
+
+	// Flags are accepted only by the commands declaring them
+	status, ok := cliCmd.getCommand("status")
+	assert.True(t, ok)
+	_, err = status.parse([]string{"--yes"}, nil)
+	assert.NotNil(t, err, "expected error on flag not accepted by the command")
+}
+
+func TestGetStepsVersion(t *testing.T) {
+	migrationList := []Migration{{Version: "3"}, {Version: "1"}, {Version: "2"}}
+
+	assert.Equal(t, "", getStepsVersion(migrationTypeUpgrade, migrationList, 0))
+	assert.Equal(t, "", getStepsVersion(migrationTypeUpgrade, migrationList, 3))
+	assert.Equal(t, "2", getStepsVersion(migrationTypeUpgrade, migrationList, 2))
+	assert.Equal(t, "3", getStepsVersion(migrationTypeDowngrade, migrationList, 1))
+}
+
+func TestCmd_Execute(t *testing.T) {
+	err := setConfigurationWithCustomOptions("false", "false", "false")
+	assert.Nil(t, err, "expected nil error on set configuration with custom options")
+	defer unsetConfiguration(t, "DBSHIFT_DUMMY_CLI_STATUS")
+
+	db := &dummyDbImplementation{envStatus: "DBSHIFT_DUMMY_CLI_STATUS"}
+	cliCmd, err := NewCmd(db)
+	assert.Nil(t, err, "expected nil error")
+
+	migrationsPath := cliCmd.getMigrationsPath()
+	writeMigrationFiles(t, migrationsPath, "20200101000000", "users")
+	writeMigrationFiles(t, migrationsPath, "20200102000000", "orders")
+	writeMigrationFiles(t, migrationsPath, "20200103000000", "invoices")
+
+	assert.Equal(t, 0, cliCmd.executeCommand([]string{"help"}))
+	assert.Equal(t, 0, cliCmd.executeCommand([]string{"help", "upgrade"}))
+	assert.Equal(t, 1, cliCmd.executeCommand([]string{"help", "unknown"}))
+	assert.Equal(t, 1, cliCmd.executeCommand([]string{"unknown"}))
+	assert.Equal(t, 1, cliCmd.executeCommand([]string{"render"}), "expected failure on missing argument")
+	assert.Equal(t, 0, cliCmd.executeCommand([]string{"upgrade", "--help"}))
+
+	// Dry run does not execute
+	assert.Equal(t, 0, cliCmd.executeCommand([]string{"upgrade", "--dry-run", "--format", "json"}))
+	status, err := db.GetStatus()
+	assert.Nil(t, err)
+	assert.Equal(t, "", status.Version)
+
+	// Steps
+	assert.Equal(t, 0, cliCmd.executeCommand([]string{"upgrade", "--steps", "2"}))
+	status, err = db.GetStatus()
+	assert.Nil(t, err)
+	assert.Equal(t, "20200102000000", status.Version)
+
+	assert.Equal(t, 0, cliCmd.executeCommand([]string{"upgrade", "--to", "20200103000000"}))
+	status, err = db.GetStatus()
+	assert.Nil(t, err)
+	assert.Equal(t, "20200103000000", status.Version)
+
+	assert.Equal(t, 0, cliCmd.executeCommand([]string{"downgrade", "--steps", "1", "--yes"}))
+	status, err = db.GetStatus()
+	assert.Nil(t, err)
+	assert.Equal(t, Status{Version: "20200103000000", Type: migrationTypeDowngrade}, *status)
+}
+
+func TestCmd_Execute_Streams(t *testing.T) {
+	err := setConfigurationWithCustomOptions("false", "false", "false")
+	assert.Nil(t, err, "expected nil error on set configuration with custom options")
+	defer unsetConfiguration(t, "DBSHIFT_DUMMY_CLI_STATUS")
+
+	db := &dummyDbImplementation{envStatus: "DBSHIFT_DUMMY_CLI_STATUS"}
+	cliCmd, err := NewCmd(db)
+	assert.Nil(t, err, "expected nil error")
+	writeMigrationFiles(t, cliCmd.getMigrationsPath(), "20200101000000", "users")
+
+	var out, errOut bytes.Buffer
+	assert.Equal(t, 0, cliCmd.Execute([]string{"--help"}, strings.NewReader(""), &out, &errOut))
+	assert.Contains(t, out.String(), "Usage: dbshift [configuration flags] <command> [flags] [arguments]")
+
+	out.Reset()
+	assert.Equal(t, 0, cliCmd.Execute([]string{"--upgrade-disabled=false", "upgrade"}, strings.NewReader(""), &out, &errOut))
+	assert.Contains(t, out.String(), "✔ Migration 20200101000000-users.up.txt has been executed")
+	assert.Empty(t, errOut.String())
+
+	// Confirmation is read from the input
+	assert.Equal(t, 1, cliCmd.Execute([]string{"downgrade"}, strings.NewReader("n\n"), &out, &errOut))
+	assert.Contains(t, errOut.String(), "downgrade has not been confirmed")
+
+	out.Reset()
+	assert.Equal(t, 0, cliCmd.Execute([]string{"downgrade"}, strings.NewReader("y\n"), &out, &errOut))
+	assert.Contains(t, out.String(), "Confirm downgrade? [y/N] ✔ Migration 20200101000000-users.down.txt has been executed")
+
+	errOut.Reset()
+	assert.Equal(t, 1, cliCmd.Execute([]string{"--unknown"}, strings.NewReader(""), &out, &errOut))
+	assert.Contains(t, errOut.String(), "flag provided but not defined")
+
+	errOut.Reset()
+	assert.Equal(t, 1, cliCmd.Execute([]string{"unknown"}, strings.NewReader(""), &out, &errOut))
+	assert.Contains(t, errOut.String(), "unknown command unknown")
+}
@@ -2,220 +2,768 @@ package dbshiftcore
 
 import (
 	"errors"
+	"flag"
 	"fmt"
 	"github.com/abiosoft/ishell"
-	"io/ioutil"
+	"github.com/abiosoft/readline"
+	"io"
 	"os"
 	"sort"
+	"strconv"
+	"strings"
 	"time"
 )
 
 type cmd struct {
-	cfg configuration
-	db  iDatabase
+	cfg     Configuration
+	base    *Configuration
+	sources configurationSources
+	db      iDatabase
+	clock   func() time.Time
+
+	// in, out and errOut are the streams of the shell-commander, the standard ones unless given to Execute.
+	in     io.Reader
+	out    io.Writer
+	errOut io.Writer
+
+	// exitCode is the exit code of the non-interactive mode, set by the commands failing on purpose (e.g. lint findings).
+	exitCode int
 }
 
-// NewCmd create a shell-commander object based on database interface and environmental configuration.
+// NewCmd create a shell-commander object based on database interface and configuration (file and environment).
 func NewCmd(db iDatabase) (*cmd, error) {
+	return newCmd(db, nil)
+}
+
+// NewCmdWithConfiguration create a shell-commander object based on database interface and a programmatic configuration.
+// The given configuration replaces the defaults, so it can still be overridden by file, environment and flags.
+func NewCmdWithConfiguration(db iDatabase, cfg Configuration) (*cmd, error) {
+	return newCmd(db, &cfg)
+}
+
+func newCmd(db iDatabase, base *Configuration) (*cmd, error) {
 
 	// Check db implementation
 	if db == nil {
 		return nil, errors.New("missing db implementation")
 	}
 
-	// Get configuration via environment
-	cfg, err := getConfiguration()
+	// Get configuration via code, file and environment
+	cfg, sources, err := resolveConfiguration(base, nil)
 	if err != nil {
 		return nil, fmt.Errorf("bad configuration: %s", err)
 	}
 
-	return &cmd{cfg: *cfg, db: db}, nil
+	c := &cmd{cfg: *cfg, base: base, sources: sources, db: db, clock: time.Now, in: os.Stdin, out: os.Stdout, errOut: os.Stderr}
+
+	// Required settings can still be given by flags, so an incomplete configuration is reported on execution
+	if checkConfiguration(*cfg) != nil {
+		return c, nil
+	}
+	if err := c.setConfiguration(cfg, sources); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// setConfiguration checks and applies the configuration, selecting its active track.
+func (c *cmd) setConfiguration(cfg *Configuration, sources configurationSources) error {
+	if err := checkConfiguration(*cfg); err != nil {
+		return fmt.Errorf("bad configuration: %s", err)
+	}
+
+	c.cfg, c.sources = *cfg, sources
+	if len(cfg.Tracks) > 0 {
+		return c.useTrack(cfg.Track)
+	}
+	return nil
 }
 
-// Run is used to execute the shell-commander.
+// Run is used to execute the shell-commander with the arguments and the standard streams of the process.
+// Without arguments it runs the interactive shell, otherwise it runs the given command and exits on failure.
 func (c *cmd) Run() {
+	var args []string
+	if len(os.Args) > 1 {
+		args = os.Args[1:]
+	}
+
+	if exitCode := c.Execute(args, os.Stdin, os.Stdout, os.Stderr); exitCode != 0 {
+		os.Exit(exitCode)
+	}
+}
+
+// Execute runs the shell-commander with the given arguments (without the program name) and streams, then returns the exit code.
+// Without arguments it runs the interactive shell. Unlike Run it never exits, so the caller can release its resources
+// (e.g. closing the database connection) or handle commands of its own before delegating the others.
+func (c *cmd) Execute(args []string, in io.Reader, out io.Writer, errOut io.Writer) int {
+	c.in, c.out, c.errOut, c.exitCode = in, out, errOut, 0
+
+	// Leading flags have the highest precedence over the configuration
+	flags, args, err := parseConfigurationFlags(args)
+	if err == flag.ErrHelp {
+		c.printUsage()
+		return 0
+	}
+	if err != nil {
+		c.printFailure(err.Error())
+		return 1
+	}
+
+	cfg, sources, err := resolveConfiguration(c.base, flags)
+	if err != nil {
+		c.printFailure("bad configuration: %s", err)
+		return 1
+	}
+	if err := c.setConfiguration(cfg, sources); err != nil {
+		c.printFailure(err.Error())
+		return 1
+	}
+
+	if len(args) > 0 {
+		return c.executeCommand(args)
+	}
 
 	// Run shell
-	shell := ishell.New()
+	shell := ishell.NewWithConfig(&readline.Config{
+		Prompt: c.getPrompt(),
+		Stdin:  readline.NewCancelableStdin(in),
+		Stdout: out,
+		Stderr: errOut,
+	})
 
 	commands := c.getShellCommands()
 	for k := range commands {
 		shell.AddCmd(commands[k])
 	}
 
-	if len(os.Args) > 1 {
-		if err := shell.Process(os.Args[1:]...); err != nil {
-			PrintFailure(err.Error())
-			os.Exit(1)
-		}
-	} else {
-		shell.Run()
-	}
+	shell.Run()
+
+	return 0
 }
 
-func (c *cmd) getShellCommands() []*ishell.Cmd {
-	return []*ishell.Cmd{{
-		Name:     "status",
-		LongHelp: "It returns the current status of database along migrations.",
-		Func:     c.handleStatus,
+func (c *cmd) getCommands() []command {
+	return []command{{
+		name:  "status",
+		help:  "It returns the current status of database along migrations. If verbose is set, it also lists the ignored files.",
+		flags: []string{flagVerbose},
+		run:   c.runStatus,
+	}, {
+		name:    "create",
+		args:    "<entity-name>",
+		maxArgs: 1,
+		help:    "It creates a entity with name. If template is set, the migration files are written from that template.",
+		flags:   []string{flagYes, flagTemplate},
+		run:     c.runCreate,
+	}, {
+		name:    "upgrade",
+		args:    "[toInclusiveVersion]",
+		maxArgs: 1,
+		help:    "It upgrades all the migrations, then it executes the changed repeatable migrations. If the version is set, it upgrades all the migrations till that version. If steps is set, it upgrades only that many migrations. If phase is set, it upgrades only the pending migrations of that phase.",
+		flags:   []string{flagYes, flagTo, flagSteps, flagPhase, flagDryRun, flagFormat},
+		run:     c.runUpgrade,
+	}, {
+		name:    "downgrade",
+		args:    "[toInclusiveVersion]",
+		maxArgs: 1,
+		help:    "It downgrades all the migrations. If the version is set, it downgrades all the migrations till that version. If steps is set, it downgrades only that many migrations.",
+		flags:   []string{flagYes, flagForce, flagTo, flagSteps, flagDryRun, flagFormat},
+		run:     c.runDowngrade,
+	}, {
+		name:    "goto",
+		args:    "<version>",
+		maxArgs: 1,
+		help:    "It upgrades or downgrades the migrations in order to reach the version.",
+		flags:   []string{flagYes, flagForce, flagDryRun, flagFormat},
+		run:     c.runGoto,
+	}, {
+		name:    "rollback",
+		args:    "[batches]",
+		maxArgs: 1,
+		help:    "It downgrades the migrations executed by the most recent batch. If batches is set, it downgrades the migrations of that many recent batches.",
+		flags:   []string{flagYes, flagForce, flagDryRun, flagFormat},
+		run:     c.runRollback,
 	}, {
-		Name:     "create",
-		Help:     "create <entity-name>",
-		LongHelp: "It creates a entity with name.",
-		Func:     c.handleCreate,
+		name:    "render",
+		args:    "<version>",
+		maxArgs: 1,
+		help:    "It prints the final content of the migrations with version, as they would be executed.",
+		run:     c.runRender,
 	}, {
-		Name:     "upgrade",
-		Help:     "upgrade [toInclusiveVersion]",
-		LongHelp: "It upgrades all the migrations. If toInclusiveId is set, it upgrades all the migrations till that version.",
-		Func:     c.handleUpgrade,
+		name:  "lint",
+		help:  "It checks the content of the pending migrations. Rules can be suppressed by the lint-ignore directive of a migration.",
+		flags: []string{flagFormat},
+		run:   c.runLint,
 	}, {
-		Name:     "downgrade",
-		Help:     "downgrade [toInclusiveVersion]",
-		LongHelp: "It downgrades all the migrations. If toInclusiveId is set, it downgrades all the migrations till that version.",
-		Func:     c.handleDowngrade,
+		name:    "export",
+		args:    "[toInclusiveVersion]",
+		maxArgs: 1,
+		help:    "It writes the pending upgrades and, without target version, the changed repeatable migrations (or the downgrades with --down) as a SQL script updating the status as well, for manual execution.",
+		flags:   []string{flagDown, flagForce, flagTo, flagOutput},
+		run:     c.runExport,
+	}, {
+		name: "hash",
+		help: "It writes the manifest (dbshift.sum) listing the version, path and content hash of every migration.",
+		run:  c.runHash,
+	}, {
+		name: "validate",
+		help: "It checks that the migrations match the manifest (dbshift.sum).",
+		run:  c.runValidate,
+	}, {
+		name:    "squash",
+		args:    "<upToVersion>",
+		maxArgs: 1,
+		help:    "It replaces the migrations till the version with a baseline migration, read from file or dumped from the database.",
+		flags:   []string{flagYes, flagFrom},
+		run:     c.runSquash,
+	}, {
+		name: "tracks",
+		help: "It returns the status of every migration track.",
+		run:  c.runTracks,
+	}, {
+		name: "config",
+		help: "It prints the effective configuration and where each value came from.",
+		run:  c.runConfig,
 	}}
 }
 
-func (c *cmd) handleStatus(ctx *ishell.Context) {
+func (c *cmd) runStatus(ctx *commandContext) error {
+	c.printHeader()
 	if err := c.status(); err != nil {
-		PrintFailure(err.Error())
+		return err
+	}
+
+	if ctx.options.verbose {
+		return c.printIgnoredFiles()
+	}
+	return nil
+}
+
+func (c *cmd) runCreate(ctx *commandContext) error {
+	c.printHeader()
+	if len(ctx.args) != 1 {
+		return errors.New("missing entity name")
+	}
+	if !ctx.options.yes && c.cfg.getOptions().IsConfirmationRequired && !c.confirm(ctx.readLine, "create", nil) {
+		return newNotConfirmedError("create")
+	}
+	return c.create(ctx.args[0], ctx.options.template)
+}
+
+func (c *cmd) runUpgrade(ctx *commandContext) error {
+	// Machine-readable output has no header
+	if ctx.options.format != formatJSON {
+		c.printHeader()
+	}
+
+	endVersion, err := ctx.getVersion()
+	if err != nil {
+		return err
+	}
+
+	migrationList, err := c.getUpgradePhasePlan(endVersion, ctx.options.phase)
+	if err != nil {
+		return err
+	}
+
+	// Steps limit the plan to the version of its last migration
+	if stepsVersion := getStepsVersion(migrationTypeUpgrade, migrationList, ctx.options.steps); stepsVersion != "" {
+		endVersion = stepsVersion
+		if migrationList, err = c.getUpgradePhasePlan(endVersion, ctx.options.phase); err != nil {
+			return err
+		}
+	}
+
+	if ctx.options.dryRun {
+		return c.printPlan("upgrade", migrationList, ctx.options.format)
+	}
+
+	if !ctx.options.yes && c.isConfirmationRequired(migrationTypeUpgrade, migrationList) && !c.confirm(ctx.readLine, "upgrade", migrationList) {
+		return newNotConfirmedError("upgrade")
+	}
+
+	if err := c.execMigrations(migrationList); err != nil {
+		return err
+	}
+
+	// Repeatable migrations follow a complete upgrade
+	if endVersion != "" {
+		return nil
+	}
+	return c.execRepeatableMigrations(ctx.options.phase)
+}
+
+func (c *cmd) runDowngrade(ctx *commandContext) error {
+	if ctx.options.format != formatJSON {
+		c.printHeader()
+	}
+
+	endVersion, err := ctx.getVersion()
+	if err != nil {
+		return err
+	}
+
+	migrationList, err := c.getDowngradePlan(endVersion, ctx.options.force)
+	if err != nil {
+		return err
+	}
+
+	if stepsVersion := getStepsVersion(migrationTypeDowngrade, migrationList, ctx.options.steps); stepsVersion != "" {
+		if migrationList, err = c.getDowngradePlan(stepsVersion, ctx.options.force); err != nil {
+			return err
+		}
+	}
+
+	if ctx.options.dryRun {
+		return c.printPlan("downgrade", migrationList, ctx.options.format)
 	}
+
+	if !ctx.options.yes && c.isConfirmationRequired(migrationTypeDowngrade, migrationList) && !c.confirm(ctx.readLine, "downgrade", migrationList) {
+		return newNotConfirmedError("downgrade")
+	}
+
+	return c.execMigrations(migrationList)
 }
 
-func (c *cmd) handleCreate(ctx *ishell.Context) {
-	if len(ctx.Args) != 1 {
-		PrintFailure("missing entity name")
-		return
+func (c *cmd) runGoto(ctx *commandContext) error {
+	if ctx.options.format != formatJSON {
+		c.printHeader()
+	}
+	if len(ctx.args) != 1 {
+		return errors.New("missing migration version")
+	}
+
+	migrationType, migrationList, err := c.getGotoPlan(ctx.args[0], ctx.options.force)
+	if err != nil {
+		return err
+	}
+
+	if ctx.options.dryRun {
+		return c.printPlan("goto", migrationList, ctx.options.format)
+	}
+
+	if !ctx.options.yes && c.isConfirmationRequired(migrationType, migrationList) && !c.confirm(ctx.readLine, "goto", migrationList) {
+		return newNotConfirmedError("goto")
+	}
+
+	return c.execMigrations(migrationList)
+}
+
+func (c *cmd) runRollback(ctx *commandContext) error {
+	if ctx.options.format != formatJSON {
+		c.printHeader()
+	}
+
+	batches := 1
+	if len(ctx.args) == 1 {
+		var err error
+		if batches, err = strconv.Atoi(ctx.args[0]); err != nil || batches < 1 {
+			return fmt.Errorf("invalid amount of batches: %s", ctx.args[0])
+		}
+	}
+
+	migrationList, err := c.getRollbackPlan(batches, ctx.options.force)
+	if err != nil {
+		return err
+	}
+
+	if ctx.options.dryRun {
+		return c.printPlan("rollback", migrationList, ctx.options.format)
+	}
+
+	if !ctx.options.yes && c.isConfirmationRequired(migrationTypeDowngrade, migrationList) && !c.confirm(ctx.readLine, "rollback", migrationList) {
+		return newNotConfirmedError("rollback")
 	}
-	name := ctx.Args[0]
-	if err := c.create(name); err != nil {
-		PrintFailure(err.Error())
+
+	return c.execMigrations(migrationList)
+}
+
+func (c *cmd) runTracks(ctx *commandContext) error {
+	c.printHeader()
+	return c.tracks()
+}
+
+func (c *cmd) runRender(ctx *commandContext) error {
+	c.printHeader()
+	if len(ctx.args) != 1 {
+		return errors.New("missing migration version")
 	}
+	return c.render(ctx.args[0])
 }
 
-func (c *cmd) handleUpgrade(ctx *ishell.Context) {
-	var endVersion string
-	if len(ctx.Args) == 1 {
-		endVersion = ctx.Args[0]
+func (c *cmd) runSquash(ctx *commandContext) error {
+	c.printHeader()
+	if len(ctx.args) != 1 {
+		return errors.New("missing migration version")
+	}
+
+	migrationList, err := c.getSquashPlan(ctx.args[0])
+	if err != nil {
+		return err
 	}
-	if err := c.upgrade(endVersion); err != nil {
-		PrintFailure(err.Error())
+
+	if !ctx.options.yes && !c.confirm(ctx.readLine, "squash", migrationList) {
+		return newNotConfirmedError("squash")
 	}
+
+	return c.squash(ctx.args[0], ctx.options.from)
 }
 
-func (c *cmd) handleDowngrade(ctx *ishell.Context) {
-	var endVersion string
-	if len(ctx.Args) == 1 {
-		endVersion = ctx.Args[0]
+func (c *cmd) runLint(ctx *commandContext) error {
+	if ctx.options.format != formatJSON {
+		c.printHeader()
 	}
-	if err := c.downgrade(endVersion); err != nil {
-		PrintFailure(err.Error())
+
+	findings, err := c.lint()
+	if err != nil {
+		return err
 	}
+
+	if err := c.printLintFindings(findings, ctx.options.format); err != nil {
+		return err
+	}
+
+	if len(findings) > 0 {
+		c.exitCode = exitCodeLint
+	}
+	return nil
 }
 
-func (c *cmd) create(migrationName string) error {
+func (c *cmd) runExport(ctx *commandContext) error {
+	// The script is written to the standard output unless a file is given
+	if ctx.options.output != "" {
+		c.printHeader()
+	}
+
+	endVersion, err := ctx.getVersion()
+	if err != nil {
+		return err
+	}
+
+	migrationType := migrationTypeUpgrade
+	if ctx.options.down {
+		migrationType = migrationTypeDowngrade
+	}
+
+	plan, err := c.getExportPlan(migrationType, endVersion, ctx.options.force)
+	if err != nil {
+		return err
+	}
+
+	if ctx.options.output == "" {
+		return c.export(c.out, plan)
+	}
+
+	// No file is created when the script cannot be exported
+	if err := c.checkExport(plan); err != nil {
+		return err
+	}
+
+	f, err := os.Create(ctx.options.output)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := c.export(f, plan); err != nil {
+		return err
+	}
+	c.printSuccess("%d migrations have been exported to %s", plan.size(), ctx.options.output)
+
+	return nil
+}
+
+func (c *cmd) runHash(ctx *commandContext) error {
+	c.printHeader()
+	return c.hash()
+}
+
+func (c *cmd) runValidate(ctx *commandContext) error {
+	c.printHeader()
+	if err := c.validate(); err != nil {
+		c.exitCode = exitCodeValidate
+		return err
+	}
+	c.printSuccess("Migrations match the manifest")
+	return nil
+}
+
+func (c *cmd) runConfig(ctx *commandContext) error {
+	c.printHeader()
+	return c.config()
+}
+
+func (c *cmd) getPrompt() string {
+	var context []string
+	for _, v := range []string{c.cfg.Environment, c.cfg.Track} {
+		if v != "" {
+			context = append(context, v)
+		}
+	}
+
+	if len(context) == 0 {
+		return ">>> "
+	}
+	return fmt.Sprintf("[%s] >>> ", strings.Join(context, "/"))
+}
+
+func (c *cmd) printHeader() {
+	if c.cfg.Environment != "" {
+		c.printf("Environment: %s\n", c.cfg.Environment)
+	}
+	if c.cfg.Track != "" {
+		c.printf("Track: %s\n", c.cfg.Track)
+	}
+}
+
+func (c *cmd) create(migrationName string, templateName string) error {
 	// Check option
-	if c.cfg.Options.IsCreateDisabled {
-		return errors.New("migration creating is disabled from options")
+	if c.cfg.getOptions().IsCreateDisabled {
+		return c.newDisabledError("creating")
 	}
 
 	// Ensure both downgrading and upgrading migrations share the same version
-	version := time.Now().Format("20060102150405")
+	version, err := c.getNextVersion()
+	if err != nil {
+		return err
+	}
 	dbExt := c.db.GetExtension()
 
+	if templateName == "" {
+		templateName = defaultTemplateName
+	}
+
+	t, err := c.renderTemplate(templateName, migrationName, version)
+	if err != nil {
+		return err
+	}
+
 	// Write downgrade file
 	migrationDowngrade := newMigration(version, migrationName, migrationTypeDowngrade, dbExt)
-	if err := ioutil.WriteFile(migrationDowngrade.getLocation(c.cfg.MigrationsPath), nil, 0664); err != nil {
+	if err := writeNewFile(migrationDowngrade.getLocation(c.getMigrationsPath()), []byte(t.Downgrade)); err != nil {
 		return err
 	}
 
 	// Write upgrade file
 	migrationUpgrade := newMigration(version, migrationName, migrationTypeUpgrade, dbExt)
-	if err := ioutil.WriteFile(migrationUpgrade.getLocation(c.cfg.MigrationsPath), nil, 0664); err != nil {
+	if err := writeNewFile(migrationUpgrade.getLocation(c.getMigrationsPath()), []byte(t.Upgrade)); err != nil {
 		return err
 	}
 
-	return nil
+	return updateManifest(c.getMigrationsPath(), nil, []Migration{migrationDowngrade, migrationUpgrade})
+}
+
+// filterByEnvironment excludes the migrations restricted to other environments.
+func (c *cmd) filterByEnvironment(migrationList []Migration) []Migration {
+	var filteredList []Migration
+	for _, m := range migrationList {
+		if m.getSkipReason(c.cfg.Environment) == "" {
+			filteredList = append(filteredList, m)
+		}
+	}
+	return filteredList
+}
+
+func (c *cmd) newDisabledError(action string) error {
+	if c.cfg.Environment != "" {
+		return fmt.Errorf("migration %s is disabled from options of environment %s", action, c.cfg.Environment)
+	}
+	return fmt.Errorf("migration %s is disabled from options", action)
 }
 
 func (c *cmd) upgrade(toInclusiveVersion string) error {
+	migrationList, err := c.getUpgradePlan(toInclusiveVersion)
+	if err != nil {
+		return err
+	}
+
+	// Execute migrations
+	if err := c.execMigrations(migrationList); err != nil {
+		return err
+	}
+
+	// Repeatable migrations follow a complete upgrade
+	if toInclusiveVersion != "" {
+		return nil
+	}
+	return c.execRepeatableMigrations("")
+}
+
+// getUpgradePlan returns the migrations to upgrade, sorted for execution.
+func (c *cmd) getUpgradePlan(toInclusiveVersion string) ([]Migration, error) {
 	// Check option
-	if c.cfg.Options.IsUpgradeDisabled {
-		return errors.New("migration upgrading is disabled from options")
+	if c.cfg.getOptions().IsUpgradeDisabled {
+		return nil, c.newDisabledError("upgrading")
+	}
+
+	// The folder must match the manifest
+	if err := c.validate(); err != nil {
+		return nil, err
 	}
 
 	// Get current version
 	status, err := c.db.GetStatus()
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	// A database part-way through squashed migrations would run the whole baseline
+	if err := c.checkSquashedStatus(*status); err != nil {
+		return nil, err
 	}
 
 	// Get migrations eligible to upgrade
-	migrationList, err := getMigrations(c.cfg.MigrationsPath, *status, toInclusiveVersion, isUpgradable)
+	upgradableFn, err := c.getUpgradableFilter(*status)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	migrationList, err := c.getMigrations(*status, toInclusiveVersion, upgradableFn)
+	if err != nil {
+		return nil, err
 	}
 
 	// Sort for execution
 	sort.Sort(upgradePerspective(migrationList))
 
+	return c.sortUpgradePlan(*status, c.filterByEnvironment(migrationList))
+}
+
+func (c *cmd) downgrade(toInclusiveVersion string) error {
+	migrationList, err := c.getDowngradePlan(toInclusiveVersion, false)
+	if err != nil {
+		return err
+	}
+
 	// Execute migrations
 	return c.execMigrations(migrationList)
 }
 
-func (c *cmd) downgrade(toInclusiveVersion string) error {
+// getDowngradePlan returns the migrations to downgrade, sorted for execution.
+func (c *cmd) getDowngradePlan(toInclusiveVersion string, isForced bool) ([]Migration, error) {
 	// Check option
-	if c.cfg.Options.IsDowngradeDisabled {
-		return errors.New("migration downgrading is disabled from options")
+	if c.cfg.getOptions().IsDowngradeDisabled {
+		return nil, c.newDisabledError("downgrading")
 	}
 
 	// Get current version
 	status, err := c.db.GetStatus()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	// Get migrations eligible to downgrade
-	migrationList, err := getMigrations(c.cfg.MigrationsPath, *status, toInclusiveVersion, isDowngradable)
+	downgradableFn, err := c.getDowngradableFilter(*status)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	migrationList, err := c.getMigrations(*status, toInclusiveVersion, downgradableFn)
+	if err != nil {
+		return nil, err
 	}
 
 	// Sort for execution
 	sort.Sort(downgradePerspective(migrationList))
+	migrationList = c.filterByEnvironment(migrationList)
 
-	// Execute migrations
-	return c.execMigrations(migrationList)
+	// Irreversible migrations cannot be crossed unless forced
+	if !isForced {
+		if err := c.checkReversibility(*status, toInclusiveVersion, migrationList); err != nil {
+			return nil, err
+		}
+	}
+
+	return c.sortDowngradePlan(*status, migrationList)
 }
 
 func (c *cmd) execMigrations(migrationList []Migration) error {
-	for _, m := range migrationList {
+	if len(migrationList) == 0 {
+		return nil
+	}
 
-		// Read migration file
-		data, err := ioutil.ReadFile(m.getLocation(c.cfg.MigrationsPath))
-		if err != nil {
-			return err
-		}
+	// Every migration of the run shares the same batch
+	batch, err := c.getNextBatch()
+	if err != nil {
+		return err
+	}
 
-		// Execute migration
-		timeStart := time.Now()
-		if err := c.db.ExecuteMigration(data); err != nil {
-			return err
+	for i, m := range migrationList {
+		m.Batch = batch
+		if err := c.execMigration(m); err != nil {
+			if !c.cfg.getOptions().IsAtomic {
+				return err
+			}
+			return c.compensate(migrationList[:i], batch, m, err)
 		}
+	}
 
-		execTimeInSeconds := time.Since(timeStart).Seconds()
-		if err := c.db.SetStatus(m, execTimeInSeconds); err != nil {
-			return err
-		}
+	return nil
+}
+
+func (c *cmd) execMigration(m Migration) error {
+	location := m.getLocation(c.getMigrationsPath())
 
-		PrintSuccess("Migration %s has been executed in %v seconds", m.Name, execTimeInSeconds)
+	// Read migration file
+	data, err := c.readMigration(m)
+	if err != nil {
+		return &MigrationError{Migration: m, Location: location, Err: err}
+	}
+
+	execTimeInSeconds, err := c.runMigration(m, location, data)
+	if err != nil {
+		return err
 	}
 
+	if err := c.db.SetStatus(m, execTimeInSeconds); err != nil {
+		return &MigrationError{Migration: m, Location: location, Err: fmt.Errorf("status not set: %s", err)}
+	}
+
+	c.printSuccess("Migration %s has been executed in %v seconds", m.Name, execTimeInSeconds)
+
 	return nil
 }
 
+// runMigration executes the content of the migration and returns the execution time.
+func (c *cmd) runMigration(m Migration, location string, data []byte) (float64, error) {
+
+	// Pass directives to the database
+	if directivesDb, ok := c.db.(iDirectivesDatabase); ok {
+		if err := directivesDb.SetDirectives(m.Directives); err != nil {
+			return 0, &MigrationError{Migration: m, Location: location, Err: err}
+		}
+	} else if m.Directives.IsTransactionDisabled || m.Directives.Timeout > 0 {
+		c.printFailure("Migration %s has directives not supported by the database implementation", m.Name)
+	}
+
+	// Execute migration
+	var err error
+	timeStart := time.Now()
+	if statementDb, ok := c.db.(iStatementDatabase); ok {
+		err = execStatements(statementDb, data)
+	} else {
+		err = c.db.ExecuteMigration(data)
+	}
+	if err != nil {
+		// Statement context is given by the statement execution
+		migrationErr, ok := err.(*MigrationError)
+		if !ok {
+			migrationErr = &MigrationError{Err: err}
+		}
+		migrationErr.Migration, migrationErr.Location = m, location
+		return 0, migrationErr
+	}
+
+	return time.Since(timeStart).Seconds(), nil
+}
+
 func (c *cmd) status() error {
 
+	// The folder must match the manifest
+	if err := c.validate(); err != nil {
+		return err
+	}
+
 	// Get current version
 	status, err := c.db.GetStatus()
 	if err != nil {
@@ -223,7 +771,12 @@ func (c *cmd) status() error {
 	}
 
 	// Get migrations eligible to upgrade
-	migrationUpgradeList, err := getMigrations(c.cfg.MigrationsPath, *status, "", isUpgradable)
+	upgradableFn, err := c.getUpgradableFilter(*status)
+	if err != nil {
+		return err
+	}
+
+	migrationUpgradeList, err := c.getMigrations(*status, "", upgradableFn)
 	if err != nil {
 		return err
 	}
@@ -231,21 +784,71 @@ func (c *cmd) status() error {
 	sort.Sort(upgradePerspective(migrationUpgradeList))
 
 	// Get migrations eligible to downgrade
-	migrationDowngradeList, err := getMigrations(c.cfg.MigrationsPath, *status, "", isDowngradable)
+	downgradableFn, err := c.getDowngradableFilter(*status)
+	if err != nil {
+		return err
+	}
+
+	migrationDowngradeList, err := c.getMigrations(*status, "", downgradableFn)
 	if err != nil {
 		return err
 	}
 
 	sort.Sort(downgradePerspective(migrationDowngradeList))
 
-	fmt.Println("Migrations to upgrade")
-	for _, m := range migrationUpgradeList {
-		fmt.Println(m.Name)
+	c.printf("Migrations to upgrade\n")
+	for _, m := range c.filterByEnvironment(migrationUpgradeList) {
+		c.printf("%s\n", c.getMigrationLabel(m))
+	}
+
+	c.printf("Migrations to downgrade\n")
+	for _, m := range c.filterByEnvironment(migrationDowngradeList) {
+		c.printf("%s\n", c.getMigrationLabel(m))
+	}
+
+	if err := c.checkSquashedStatus(*status); err != nil {
+		c.printFailure(err.Error())
+	}
+
+	// Pending work per deployment phase
+	c.printPhases(c.filterByEnvironment(migrationUpgradeList))
+
+	// Migrations restricted to other environments
+	var isSkippedHeaderPrinted bool
+	for _, m := range append(migrationUpgradeList, migrationDowngradeList...) {
+		if reason := m.getSkipReason(c.cfg.Environment); reason != "" {
+			if !isSkippedHeaderPrinted {
+				c.printf("Migrations skipped\n")
+				isSkippedHeaderPrinted = true
+			}
+			c.printf("%s (%s)\n", c.getMigrationLabel(m), reason)
+		}
+	}
+
+	// Get repeatable migrations changed since their last execution
+	repeatableList, err := c.getRepeatablePlan()
+	if err != nil {
+		return err
+	}
+
+	if len(repeatableList) > 0 {
+		c.printf("Repeatable migrations to execute\n")
+		for _, r := range repeatableList {
+			c.printf("%s\n", r.Name)
+		}
+	}
+
+	return nil
+}
+
+func (c *cmd) config() error {
+	entries, err := getConfigurationEntries(c.cfg, c.sources)
+	if err != nil {
+		return err
 	}
 
-	fmt.Println("Migrations to downgrade")
-	for _, m := range migrationDowngradeList {
-		fmt.Println(m.Name)
+	for _, e := range entries {
+		c.printf("%s = %s [%s]\n", e.Key, e.Value, e.Source)
 	}
 
 	return nil
@@ -1,11 +1,14 @@
 package dbshiftcore
 
 import (
+	"bytes"
 	"encoding/json"
 	"github.com/stretchr/testify/assert"
+	"io/ioutil"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -13,6 +16,7 @@ var c *cmd
 
 type dummyDbImplementation struct {
 	envStatus string
+	history   []Migration
 }
 
 func (db *dummyDbImplementation) GetExtension() string {
@@ -49,9 +53,15 @@ func (db *dummyDbImplementation) SetStatus(migration Migration, executionTimeInS
 		return err
 	}
 
+	db.history = append(db.history, migration)
+
 	return nil
 }
 
+func (db *dummyDbImplementation) GetHistory() ([]Migration, error) {
+	return db.history, nil
+}
+
 func (db *dummyDbImplementation) ExecuteMigration([]byte) error {
 	return nil
 }
@@ -62,8 +72,28 @@ func TestNewCmd_NoImplementation(t *testing.T) {
 }
 
 func TestNewCmd_NoConfiguration(t *testing.T) {
-	_, err := NewCmd(new(dummyDbImplementation))
-	assert.NotNil(t, err, "expected missing configuration")
+	noCfgCmd, err := NewCmd(new(dummyDbImplementation))
+	assert.Nil(t, err, "expected missing configuration to be reported on execution")
+
+	var out, errOut bytes.Buffer
+	assert.Equal(t, 1, noCfgCmd.Execute([]string{"status"}, strings.NewReader(""), &out, &errOut))
+	assert.Contains(t, errOut.String(), "migrations path is not set")
+}
+
+func TestCmd_Execute_MigrationsFlag(t *testing.T) {
+	migrationsPath := filepath.Join("./tmp/dbshift-flag")
+	assert.Nil(t, os.MkdirAll(migrationsPath, 0777))
+	defer os.RemoveAll(migrationsPath)
+	assert.Nil(t, os.Unsetenv(envPathMigrations))
+
+	// The documented example: the migrations path is given by flag only
+	flagCmd, err := NewCmd(&dummyDbImplementation{envStatus: "DBSHIFT_DUMMY_FLAG_STATUS"})
+	assert.Nil(t, err, "expected nil error")
+
+	var out, errOut bytes.Buffer
+	assert.Equal(t, 0, flagCmd.Execute([]string{"--migrations", migrationsPath, "status"}, strings.NewReader(""), &out, &errOut))
+	assert.Empty(t, errOut.String())
+	assert.Equal(t, "flag (--migrations)", flagCmd.sources.get("migrationsPath"))
 }
 
 func TestNewCmd(t *testing.T) {
@@ -87,15 +117,19 @@ func TestCmd_Run_WithArgs(t *testing.T) {
 
 func TestGetShellCommands(t *testing.T) {
 	cmdList := c.getShellCommands()
-	assert.Equal(t, 4, len(cmdList), "expected specific amount of commands")
+	assert.Equal(t, 14, len(cmdList), "expected specific amount of commands")
 }
 
 func TestCmd_HandleStatus(t *testing.T) {
 	assert.Nil(t, c.status(), "expect nil error handling status")
 }
 
+func TestCmd_HandleConfig(t *testing.T) {
+	assert.Nil(t, c.config(), "expect nil error handling config")
+}
+
 func TestCmd_HandleCreate(t *testing.T) {
-	assert.Nil(t, c.create("some-migration"), "expect nil error handling create")
+	assert.Nil(t, c.create("some-migration", ""), "expect nil error handling create")
 }
 
 func TestCmd_HandleUpgrade(t *testing.T) {
@@ -107,7 +141,7 @@ func TestCmd_HandleDowngrade(t *testing.T) {
 }
 
 func TestCmdCreate(t *testing.T) {
-	err := c.create("my-beautiful-migration")
+	err := c.create("my-beautiful-migration", "")
 
 	migrationsPath := os.Getenv("DBSHIFT_ABS_FOLDER_MIGRATIONS")
 	assert.NotEmpty(t, migrationsPath, "expected valid migration path")
@@ -134,6 +168,44 @@ func TestCmdDowngrade(t *testing.T) {
 	assert.Nil(t, err, "expect nil error on downgrade")
 }
 
+func TestNewCmdWithConfiguration(t *testing.T) {
+	err := setConfigurationWithCustomOptions("false", "false", "false")
+	assert.Nil(t, err, "expected nil error on set configuration with custom options")
+
+	cmdWithConfiguration, err := NewCmdWithConfiguration(new(dummyDbImplementation), Configuration{
+		MigrationsPath: "./unexisting/folder",
+		Options:        ConfigurationOptions{IsCreateDisabled: true},
+	})
+	assert.Nil(t, err, "expected nil error")
+
+	// Environment overrides code
+	assert.Equal(t, os.Getenv(envPathMigrations), cmdWithConfiguration.cfg.MigrationsPath)
+	assert.False(t, cmdWithConfiguration.cfg.Options.IsCreateDisabled)
+	assert.Equal(t, "env (DBSHIFT_ABS_FOLDER_MIGRATIONS)", cmdWithConfiguration.sources.get("migrationsPath"))
+}
+
+func TestCmd_Environment(t *testing.T) {
+	err := setConfigurationWithCustomOptions("false", "false", "false")
+	assert.Nil(t, err, "expected nil error on set configuration with custom options")
+
+	envCmd, err := NewCmdWithConfiguration(new(dummyDbImplementation), Configuration{
+		Environment: "prod",
+		Environments: map[string]ConfigurationOptions{
+			"prod": {IsCreateDisabled: true, IsConfirmationRequired: true},
+		},
+	})
+	assert.Nil(t, err, "expected nil error")
+
+	assert.Equal(t, "[prod] >>> ", envCmd.getPrompt())
+	assert.NotNil(t, envCmd.create("some-migration", ""), "expect error on create because disabled by environment")
+
+	assert.True(t, envCmd.isConfirmationRequired(migrationTypeUpgrade, make([]Migration, 1)))
+
+	envCmd.cfg.Environment = ""
+	assert.Equal(t, ">>> ", envCmd.getPrompt())
+	assert.False(t, envCmd.isConfirmationRequired(migrationTypeUpgrade, make([]Migration, 1)), "expected no confirmation outside environment")
+}
+
 // When Disabled
 
 func TestNewCmd_Disabled(t *testing.T) {
@@ -144,7 +216,7 @@ func TestNewCmd_Disabled(t *testing.T) {
 }
 
 func TestCmd_Create_Disabled(t *testing.T) {
-	err := c.create("")
+	err := c.create("", "")
 	assert.NotNil(t, err, "expect error on create because disabled")
 }
 
@@ -193,6 +265,21 @@ func setConfigurationWithCustomOptions(isCreateDisabled, isDowngradeDisabled, is
 	return nil
 }
 
+// unsetConfiguration restores the environment for the tests relying on a missing configuration
+func unsetConfiguration(t *testing.T, envStatus string) {
+	unsetOptions(t)
+	assert.Nil(t, os.Unsetenv(envPathMigrations))
+	assert.Nil(t, os.Unsetenv(envStatus))
+}
+
+func writeMigrationFiles(t *testing.T, migrationsPath string, version string, name string) {
+	for _, migrationType := range []migrationType{migrationTypeDowngrade, migrationTypeUpgrade} {
+		m := newMigration(version, name, migrationType, "txt")
+		err := ioutil.WriteFile(m.getLocation(migrationsPath), nil, 0664)
+		assert.Nil(t, err)
+	}
+}
+
 func setIsCreateDisabled(v string) error {
 	return os.Setenv("DBSHIFT_OPTION_IS_CREATE_DISABLED", v)
 }
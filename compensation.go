@@ -0,0 +1,52 @@
+package dbshiftcore
+
+import (
+	"fmt"
+)
+
+// RunError is the error of an atomic run: it reports the failed migration and the result of the compensation.
+type RunError struct {
+	Migration       Migration
+	Err             error
+	Compensated     []Migration
+	CompensationErr error
+}
+
+func (e *RunError) Error() string {
+	text := e.Err.Error()
+	if e.CompensationErr != nil {
+		return fmt.Sprintf("%s; compensation failed after reverting %d migrations: %s", text, len(e.Compensated), e.CompensationErr)
+	}
+	return fmt.Sprintf("%s; compensation reverted %d migrations", text, len(e.Compensated))
+}
+
+// Unwrap returns the original failure.
+func (e *RunError) Unwrap() error {
+	return e.Err
+}
+
+// compensate reverts the migrations applied by the run, in reverse order.
+func (c *cmd) compensate(appliedList []Migration, batch uint, failed Migration, failure error) error {
+	runErr := &RunError{Migration: failed, Err: failure}
+
+	c.printFailure("Migration %s failed, reverting %d migrations", failed.Name, len(appliedList))
+
+	for i := len(appliedList) - 1; i >= 0; i-- {
+		counterpart := appliedList[i].getCounterpart()
+		counterpart.Batch = batch
+
+		var err error
+		if counterpart.Directives, err = readDirectives(counterpart.getLocation(c.getMigrationsPath())); err != nil {
+			runErr.CompensationErr = &MigrationError{Migration: counterpart, Location: counterpart.getLocation(c.getMigrationsPath()), Err: err}
+			return runErr
+		}
+
+		if err := c.execMigration(counterpart); err != nil {
+			runErr.CompensationErr = err
+			return runErr
+		}
+		runErr.Compensated = append(runErr.Compensated, counterpart)
+	}
+
+	return runErr
+}
@@ -0,0 +1,82 @@
+package dbshiftcore
+
+import (
+	"bytes"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestCmd_ExecMigrations_Atomic(t *testing.T) {
+	err := setConfigurationWithCustomOptions("false", "false", "false")
+	assert.Nil(t, err, "expected nil error on set configuration with custom options")
+	defer unsetConfiguration(t, "DBSHIFT_DUMMY_ATOMIC_STATUS")
+
+	db := &failingDbImplementation{
+		dummyDbImplementation: dummyDbImplementation{envStatus: "DBSHIFT_DUMMY_ATOMIC_STATUS"},
+		failOn:                []byte("FAIL"),
+	}
+	atomicCmd, err := NewCmd(db)
+	assert.Nil(t, err, "expected nil error")
+	atomicCmd.cfg.Options.IsAtomic = true
+
+	migrationsPath := os.Getenv(envPathMigrations)
+	writeMigrationFiles(t, migrationsPath, "20200101000000", "first")
+	writeMigrationFiles(t, migrationsPath, "20200102000000", "second")
+	writeMigrationFiles(t, migrationsPath, "20200103000000", "third")
+
+	failing := newMigration("20200103000000", "third", migrationTypeUpgrade, "txt")
+	err = ioutil.WriteFile(failing.getLocation(migrationsPath), []byte("FAIL"), 0664)
+	assert.Nil(t, err)
+
+	err = atomicCmd.upgrade("")
+	runErr, ok := err.(*RunError)
+	assert.True(t, ok, "expected run error")
+	assert.Equal(t, failing.Name, runErr.Migration.Name)
+	assert.True(t, errors.Is(runErr, errFailingMigration))
+	assert.Nil(t, runErr.CompensationErr)
+	assert.Equal(t, []string{"20200102000000", "20200101000000"}, getVersions(runErr.Compensated))
+
+	// Every migration applied by the run has been reverted
+	assert.Empty(t, getAppliedUpgrades(db.history))
+	for _, m := range db.history {
+		assert.Equal(t, uint(1), m.Batch)
+	}
+
+	// Compensation failure
+	compensating := newMigration("20200102000000", "second", migrationTypeDowngrade, "txt")
+	err = ioutil.WriteFile(compensating.getLocation(migrationsPath), []byte("FAIL"), 0664)
+	assert.Nil(t, err)
+
+	err = atomicCmd.upgrade("")
+	runErr, ok = err.(*RunError)
+	assert.True(t, ok, "expected run error")
+	assert.NotNil(t, runErr.CompensationErr)
+	assert.Empty(t, runErr.Compensated)
+	assert.Contains(t, runErr.Error(), "compensation failed")
+
+	// Without atomic mode the original error is returned
+	atomicCmd.cfg.Options.IsAtomic = false
+	err = atomicCmd.upgrade("")
+	assert.True(t, errors.Is(err, errFailingMigration))
+	_, ok = err.(*RunError)
+	assert.False(t, ok, "expected migration error only")
+}
+
+// Helpers
+
+var errFailingMigration = errors.New("failing migration")
+
+type failingDbImplementation struct {
+	dummyDbImplementation
+	failOn []byte
+}
+
+func (db *failingDbImplementation) ExecuteMigration(data []byte) error {
+	if bytes.Contains(data, db.failOn) {
+		return errFailingMigration
+	}
+	return nil
+}
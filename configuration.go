@@ -1,51 +1,327 @@
 package dbshiftcore
 
 import (
+	"bytes"
+	"encoding/json"
+	"flag"
 	"fmt"
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"sort"
 	"strconv"
+	"strings"
 )
 
 const (
-	envPathMigrations            = "DBSHIFT_ABS_FOLDER_MIGRATIONS"
-	envOptionIsCreateDisabled    = "DBSHIFT_OPTION_IS_CREATE_DISABLED"
-	envOptionIsDowngradeDisabled = "DBSHIFT_OPTION_IS_DOWNGRADE_DISABLED"
-	envOptionIsUpgradeDisabled   = "DBSHIFT_OPTION_IS_UPGRADE_DISABLED"
+	envConfigurationFile            = "DBSHIFT_CONFIG_FILE"
+	envEnvironment                  = "DBSHIFT_ENV"
+	envTrack                        = "DBSHIFT_TRACK"
+	envTemplatesPath                = "DBSHIFT_TEMPLATES_PATH"
+	envAuthor                       = "DBSHIFT_AUTHOR"
+	envPathMigrations               = "DBSHIFT_ABS_FOLDER_MIGRATIONS"
+	envOptionIsCreateDisabled       = "DBSHIFT_OPTION_IS_CREATE_DISABLED"
+	envOptionIsDowngradeDisabled    = "DBSHIFT_OPTION_IS_DOWNGRADE_DISABLED"
+	envOptionIsUpgradeDisabled      = "DBSHIFT_OPTION_IS_UPGRADE_DISABLED"
+	envOptionIsConfirmationRequired = "DBSHIFT_OPTION_IS_CONFIRMATION_REQUIRED"
+	envOptionConfirmationThreshold  = "DBSHIFT_OPTION_CONFIRMATION_THRESHOLD"
+	envOptionIsAtomic               = "DBSHIFT_OPTION_IS_ATOMIC"
+	envOptionIsTemplatingEnabled    = "DBSHIFT_OPTION_IS_TEMPLATING_ENABLED"
+	envOptionIsStrict               = "DBSHIFT_OPTION_IS_STRICT"
+	envPrefixVariable               = "DBSHIFT_VAR_"
 )
 
-type configuration struct {
-	MigrationsPath string
-	Options        configurationOptions
+const (
+	flagConfigurationFile = "config"
+	configurationFileName = "dbshift.json"
+)
+
+// Configuration sources, from the lowest to the highest precedence.
+const (
+	configurationSourceDefault = "default"
+	configurationSourceCode    = "code"
+	configurationSourceFile    = "file"
+	configurationSourceEnv     = "env"
+	configurationSourceFlag    = "flag"
+)
+
+// Configuration is the structure holding the core settings.
+// It can be passed directly to NewCmdWithConfiguration and it is the schema of the configuration file.
+type Configuration struct {
+	MigrationsPath string                          `json:"migrationsPath"`
+	Options        ConfigurationOptions            `json:"options"`
+	Environment    string                          `json:"environment"`
+	Environments   map[string]ConfigurationOptions `json:"environments"`
+	Variables      map[string]string               `json:"variables"`
+	Track          string                          `json:"track"`
+	Tracks         map[string]string               `json:"tracks"`
+	TemplatesPath  string                          `json:"templatesPath"`
+	Author         string                          `json:"author"`
 }
 
-type configurationOptions struct {
-	IsCreateDisabled    bool
-	IsDowngradeDisabled bool
-	IsUpgradeDisabled   bool
+// ConfigurationOptions is the structure holding the optional core settings.
+// It is also used as policy of a named environment.
+type ConfigurationOptions struct {
+	IsCreateDisabled       bool `json:"isCreateDisabled"`
+	IsDowngradeDisabled    bool `json:"isDowngradeDisabled"`
+	IsUpgradeDisabled      bool `json:"isUpgradeDisabled"`
+	IsConfirmationRequired bool `json:"isConfirmationRequired"`
+	ConfirmationThreshold  int  `json:"confirmationThreshold"`
+	IsAtomic               bool `json:"isAtomic"`
+	IsTemplatingEnabled    bool `json:"isTemplatingEnabled"`
+	IsStrict               bool `json:"isStrict"`
 }
 
-func getConfiguration() (*configuration, error) {
-	folderMigrations, err := getEnvVar(envPathMigrations)
-	if err != nil {
-		return nil, err
+// getOptions returns the options merged with the policy of the active environment.
+// Policies can only restrict: a disabled command, a required confirmation, a lower threshold, an atomic run or the strict mode always wins.
+func (cfg Configuration) getOptions() ConfigurationOptions {
+	options := cfg.Options
+	if policy, ok := cfg.Environments[cfg.Environment]; ok {
+		options.IsCreateDisabled = options.IsCreateDisabled || policy.IsCreateDisabled
+		options.IsDowngradeDisabled = options.IsDowngradeDisabled || policy.IsDowngradeDisabled
+		options.IsUpgradeDisabled = options.IsUpgradeDisabled || policy.IsUpgradeDisabled
+		options.IsConfirmationRequired = options.IsConfirmationRequired || policy.IsConfirmationRequired
+		options.IsAtomic = options.IsAtomic || policy.IsAtomic
+		options.IsTemplatingEnabled = options.IsTemplatingEnabled || policy.IsTemplatingEnabled
+		options.IsStrict = options.IsStrict || policy.IsStrict
+		if policy.ConfirmationThreshold > 0 && (options.ConfirmationThreshold == 0 || policy.ConfirmationThreshold < options.ConfirmationThreshold) {
+			options.ConfirmationThreshold = policy.ConfirmationThreshold
+		}
 	}
+	return options
+}
+
+// configurationSetting describes a single setting which can be overridden via environment and flag.
+type configurationSetting struct {
+	key    string
+	env    string
+	flag   string
+	usage  string
+	isBool bool
+	set    func(cfg *Configuration, value string) error
+}
 
-	// Check if migrations path exists
-	err = checkMigrationPath(folderMigrations)
+var configurationSettings = []configurationSetting{{
+	key:   "environment",
+	env:   envEnvironment,
+	flag:  "env",
+	usage: "name of the active environment",
+	set: func(cfg *Configuration, value string) error {
+		cfg.Environment = value
+		return nil
+	},
+}, {
+	key:   "track",
+	env:   envTrack,
+	flag:  "track",
+	usage: "name of the active migration track",
+	set: func(cfg *Configuration, value string) error {
+		cfg.Track = value
+		return nil
+	},
+}, {
+	key:   "migrationsPath",
+	env:   envPathMigrations,
+	flag:  "migrations",
+	usage: "folder where migrations are created and stored",
+	set: func(cfg *Configuration, value string) error {
+		cfg.MigrationsPath = value
+		return nil
+	},
+}, {
+	key:   "templatesPath",
+	env:   envTemplatesPath,
+	flag:  "templates",
+	usage: "folder of the templates used by create",
+	set: func(cfg *Configuration, value string) error {
+		cfg.TemplatesPath = value
+		return nil
+	},
+}, {
+	key:   "author",
+	env:   envAuthor,
+	flag:  "author",
+	usage: "author of the migrations written by create",
+	set: func(cfg *Configuration, value string) error {
+		cfg.Author = value
+		return nil
+	},
+}, {
+	key:    "options.isCreateDisabled",
+	env:    envOptionIsCreateDisabled,
+	flag:   "create-disabled",
+	usage:  "disable create command",
+	isBool: true,
+	set: func(cfg *Configuration, value string) (err error) {
+		cfg.Options.IsCreateDisabled, err = strconv.ParseBool(value)
+		return err
+	},
+}, {
+	key:    "options.isDowngradeDisabled",
+	env:    envOptionIsDowngradeDisabled,
+	flag:   "downgrade-disabled",
+	usage:  "disable downgrade command",
+	isBool: true,
+	set: func(cfg *Configuration, value string) (err error) {
+		cfg.Options.IsDowngradeDisabled, err = strconv.ParseBool(value)
+		return err
+	},
+}, {
+	key:    "options.isUpgradeDisabled",
+	env:    envOptionIsUpgradeDisabled,
+	flag:   "upgrade-disabled",
+	usage:  "disable upgrade command",
+	isBool: true,
+	set: func(cfg *Configuration, value string) (err error) {
+		cfg.Options.IsUpgradeDisabled, err = strconv.ParseBool(value)
+		return err
+	},
+}, {
+	key:    "options.isConfirmationRequired",
+	env:    envOptionIsConfirmationRequired,
+	flag:   "confirmation-required",
+	usage:  "require confirmation before changing the database",
+	isBool: true,
+	set: func(cfg *Configuration, value string) (err error) {
+		cfg.Options.IsConfirmationRequired, err = strconv.ParseBool(value)
+		return err
+	},
+}, {
+	key:   "options.confirmationThreshold",
+	env:   envOptionConfirmationThreshold,
+	flag:  "confirmation-threshold",
+	usage: "number of migrations from which an upgrade requires confirmation",
+	set: func(cfg *Configuration, value string) (err error) {
+		cfg.Options.ConfirmationThreshold, err = strconv.Atoi(value)
+		return err
+	},
+}, {
+	key:    "options.isAtomic",
+	env:    envOptionIsAtomic,
+	flag:   "atomic",
+	usage:  "revert the migrations of a failed run",
+	isBool: true,
+	set: func(cfg *Configuration, value string) (err error) {
+		cfg.Options.IsAtomic, err = strconv.ParseBool(value)
+		return err
+	},
+}, {
+	key:    "options.isTemplatingEnabled",
+	env:    envOptionIsTemplatingEnabled,
+	flag:   "templating",
+	usage:  "render migrations as templates with the configured variables",
+	isBool: true,
+	set: func(cfg *Configuration, value string) (err error) {
+		cfg.Options.IsTemplatingEnabled, err = strconv.ParseBool(value)
+		return err
+	},
+}, {
+	key:    "options.isStrict",
+	env:    envOptionIsStrict,
+	flag:   "strict",
+	usage:  "consider irreversible the migrations with a missing or empty downgrade",
+	isBool: true,
+	set: func(cfg *Configuration, value string) (err error) {
+		cfg.Options.IsStrict, err = strconv.ParseBool(value)
+		return err
+	},
+}}
+
+// configurationSources maps every configuration key to the source of its value.
+type configurationSources map[string]string
+
+func (s configurationSources) get(key string) string {
+	if source, ok := s[key]; ok {
+		return source
+	}
+	return configurationSourceDefault
+}
+
+// configurationEntry is a single effective configuration value along with its source.
+type configurationEntry struct {
+	Key    string
+	Value  string
+	Source string
+}
+
+func getConfiguration() (*Configuration, error) {
+	cfg, _, err := resolveConfiguration(nil, nil)
 	if err != nil {
 		return nil, err
 	}
+	if err := checkConfiguration(*cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
 
-	// Optional values
-	options, err := getOptions()
+// resolveConfiguration merges the configuration layers: defaults (or code) < file < env < flags.
+// The result is not checked, since settings missing from a layer can still be given by a following one.
+func resolveConfiguration(base *Configuration, flags map[string]string) (*Configuration, configurationSources, error) {
+	cfg := Configuration{}
+	sources := configurationSources{}
+
+	// Programmatic configuration replaces defaults
+	if base != nil {
+		cfg = *base
+		if err := setSources(cfg, sources, configurationSourceCode); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// Configuration file
+	fileLocation, err := getConfigurationFileLocation(flags)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+	if fileLocation != "" {
+		if err := applyConfigurationFile(&cfg, sources, fileLocation); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// Environment variables
+	if err := applyEnvVars(&cfg, sources); err != nil {
+		return nil, nil, err
+	}
+
+	// Flags
+	if err := applyFlags(&cfg, sources, flags); err != nil {
+		return nil, nil, err
+	}
+
+	return &cfg, sources, nil
+}
+
+func checkConfiguration(cfg Configuration) error {
+	if cfg.MigrationsPath == "" {
+		return fmt.Errorf("migrations path is not set: use %s, the configuration file or a flag", envPathMigrations)
 	}
 
-	return &configuration{
-		MigrationsPath: folderMigrations,
-		Options:        *options,
-	}, nil
+	// Check if the active environment is defined
+	if cfg.Environment != "" {
+		if _, ok := cfg.Environments[cfg.Environment]; !ok {
+			return fmt.Errorf("environment %s is not defined", cfg.Environment)
+		}
+	}
+
+	// Check if the active track is defined
+	if cfg.Track != "" {
+		if _, ok := cfg.Tracks[cfg.Track]; !ok {
+			return fmt.Errorf("track %s is not defined", cfg.Track)
+		}
+	}
+
+	// Check if migrations paths exist
+	for _, name := range cfg.getTrackNames() {
+		for _, path := range cfg.getTrackPaths(name) {
+			if err := checkMigrationPath(path); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
 }
 
 func checkMigrationPath(migrationsPath string) error {
@@ -56,31 +332,207 @@ func checkMigrationPath(migrationsPath string) error {
 	return nil
 }
 
-func getOptions() (*configurationOptions, error) {
-	var err error
-	options := configurationOptions{}
+// getConfigurationFileLocation returns the configuration file given by flag or env, otherwise the one discovered from the working directory.
+func getConfigurationFileLocation(flags map[string]string) (string, error) {
+	if location, ok := flags[flagConfigurationFile]; ok {
+		return location, nil
+	}
 
-	if options.IsCreateDisabled, err = getBooleanOption(envOptionIsCreateDisabled); err != nil {
-		return nil, err
+	if location, err := getEnvVar(envConfigurationFile); err == nil {
+		return location, nil
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+
+	location := filepath.Join(wd, configurationFileName)
+	if _, err := os.Stat(location); err != nil {
+		return "", nil
+	}
+
+	return location, nil
+}
+
+func applyConfigurationFile(cfg *Configuration, sources configurationSources, location string) error {
+	data, err := ioutil.ReadFile(location)
+	if err != nil {
+		return fmt.Errorf("bad configuration file: %s", err)
 	}
 
-	if options.IsDowngradeDisabled, err = getBooleanOption(envOptionIsDowngradeDisabled); err != nil {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(cfg); err != nil {
+		return fmt.Errorf("bad configuration file %s: %s", location, err)
+	}
+
+	// Only the keys written in the file come from the file
+	var values interface{}
+	if err := json.Unmarshal(data, &values); err != nil {
+		return err
+	}
+
+	source := fmt.Sprintf("%s (%s)", configurationSourceFile, location)
+	for _, entry := range flattenConfigurationValue("", values) {
+		sources[entry.Key] = source
+	}
+
+	return nil
+}
+
+func applyEnvVars(cfg *Configuration, sources configurationSources) error {
+	for _, setting := range configurationSettings {
+		value, err := getEnvVar(setting.env)
+		if err != nil {
+			continue
+		}
+		if err := setting.set(cfg, value); err != nil {
+			return fmt.Errorf("bad value for %s: %s", setting.env, err)
+		}
+		sources[setting.key] = fmt.Sprintf("%s (%s)", configurationSourceEnv, setting.env)
+	}
+
+	// Template variables
+	for _, envVar := range os.Environ() {
+		kv := strings.SplitN(envVar, "=", 2)
+		if len(kv) != 2 || !strings.HasPrefix(kv[0], envPrefixVariable) || kv[0] == envPrefixVariable {
+			continue
+		}
+		if cfg.Variables == nil {
+			cfg.Variables = map[string]string{}
+		}
+		name := strings.TrimPrefix(kv[0], envPrefixVariable)
+		cfg.Variables[name] = kv[1]
+		sources[joinConfigurationKey("variables", name)] = fmt.Sprintf("%s (%s)", configurationSourceEnv, kv[0])
+	}
+
+	return nil
+}
+
+func applyFlags(cfg *Configuration, sources configurationSources, flags map[string]string) error {
+	for _, setting := range configurationSettings {
+		value, ok := flags[setting.flag]
+		if !ok {
+			continue
+		}
+		if err := setting.set(cfg, value); err != nil {
+			return fmt.Errorf("bad value for --%s: %s", setting.flag, err)
+		}
+		sources[setting.key] = fmt.Sprintf("%s (--%s)", configurationSourceFlag, setting.flag)
+	}
+	return nil
+}
+
+// parseConfigurationFlags parses the leading configuration flags and returns them along with the remaining arguments.
+func parseConfigurationFlags(args []string) (map[string]string, []string, error) {
+	fs := flag.NewFlagSet("dbshift", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+
+	fs.Var(&configurationFlagValue{}, flagConfigurationFile, "configuration file")
+	for _, setting := range configurationSettings {
+		fs.Var(&configurationFlagValue{isBool: setting.isBool}, setting.flag, setting.usage)
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return nil, nil, err
+	}
+
+	flags := map[string]string{}
+	fs.Visit(func(f *flag.Flag) {
+		flags[f.Name] = f.Value.String()
+	})
+
+	return flags, fs.Args(), nil
+}
+
+// configurationFlagValue is a flag value kept as string, validated later by the configuration setting.
+type configurationFlagValue struct {
+	value  string
+	isBool bool
+}
+
+func (v *configurationFlagValue) String() string {
+	return v.value
+}
+
+func (v *configurationFlagValue) Set(value string) error {
+	v.value = value
+	return nil
+}
+
+func (v *configurationFlagValue) IsBoolFlag() bool {
+	return v.isBool
+}
+
+// getConfigurationEntries returns the effective configuration as a sorted list of key-value pairs with their source.
+func getConfigurationEntries(cfg Configuration, sources configurationSources) ([]configurationEntry, error) {
+	values, err := getConfigurationValues(cfg)
+	if err != nil {
 		return nil, err
 	}
 
-	if options.IsUpgradeDisabled, err = getBooleanOption(envOptionIsUpgradeDisabled); err != nil {
+	entries := flattenConfigurationValue("", values)
+	for i := range entries {
+		entries[i].Source = sources.get(entries[i].Key)
+	}
+
+	return entries, nil
+}
+
+func setSources(cfg Configuration, sources configurationSources, source string) error {
+	values, err := getConfigurationValues(cfg)
+	if err != nil {
+		return err
+	}
+	for _, entry := range flattenConfigurationValue("", values) {
+		sources[entry.Key] = source
+	}
+	return nil
+}
+
+func getConfigurationValues(cfg Configuration) (interface{}, error) {
+	data, err := json.Marshal(cfg)
+	if err != nil {
 		return nil, err
 	}
 
-	return &options, nil
+	var values interface{}
+	err = json.Unmarshal(data, &values)
+	return values, err
+}
+
+func flattenConfigurationValue(prefix string, value interface{}) []configurationEntry {
+	var entries []configurationEntry
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			entries = append(entries, flattenConfigurationValue(joinConfigurationKey(prefix, k), v[k])...)
+		}
+	case []interface{}:
+		for i := range v {
+			entries = append(entries, flattenConfigurationValue(joinConfigurationKey(prefix, strconv.Itoa(i)), v[i])...)
+		}
+	case nil:
+		entries = append(entries, configurationEntry{Key: prefix})
+	default:
+		entries = append(entries, configurationEntry{Key: prefix, Value: fmt.Sprint(v)})
+	}
+
+	return entries
 }
 
-func getBooleanOption(envKey string) (bool, error) {
-	optionEnv, err := getEnvVar(envKey)
-	if err == nil {
-		return strconv.ParseBool(optionEnv)
+func joinConfigurationKey(prefix string, key string) string {
+	if prefix == "" {
+		return key
 	}
-	return false, nil
+	return prefix + "." + key
 }
 
 func getEnvVar(key string) (string, error) {
@@ -2,6 +2,7 @@ package dbshiftcore
 
 import (
 	"github.com/stretchr/testify/assert"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"testing"
@@ -17,7 +18,7 @@ func TestGetConfiguration_MissingMigrationPath(t *testing.T) {
 	errCheck := checkMigrationPath(migrationsPath)
 	assert.NotNil(t, errCheck)
 
-	_, errOptions := getOptions()
+	errOptions := applyEnvVars(&Configuration{}, configurationSources{})
 	assert.Nil(t, errOptions)
 
 	cfg, err := getConfiguration()
@@ -31,7 +32,7 @@ func TestGetConfiguration_UnexistingMigrationPath(t *testing.T) {
 	errCheck := checkMigrationPath(migrationsPath)
 	assert.NotNil(t, errCheck)
 
-	_, errOptions := getOptions()
+	errOptions := applyEnvVars(&Configuration{}, configurationSources{})
 	assert.Nil(t, errOptions)
 
 	cfg, err := getConfiguration()
@@ -46,7 +47,7 @@ func TestGetConfiguration_InvalidOptions(t *testing.T) {
 	assert.Nil(t, errCheck)
 
 	setInvalidOption(t)
-	_, errOptions := getOptions()
+	errOptions := applyEnvVars(&Configuration{}, configurationSources{})
 	assert.NotNil(t, errOptions)
 
 	cfg, err := getConfiguration()
@@ -61,7 +62,7 @@ func TestGetConfiguration_Present(t *testing.T) {
 	assert.Nil(t, errCheck)
 
 	unsetInvalidOption(t)
-	_, errOptions := getOptions()
+	errOptions := applyEnvVars(&Configuration{}, configurationSources{})
 	assert.Nil(t, errOptions)
 
 	cfg, err := getConfiguration()
@@ -82,7 +83,7 @@ func TestCheckMigrationPath_Present(t *testing.T) {
 	assert.Nil(t, err, "expected available migration path")
 }
 
-func TestGetOptions(t *testing.T) {
+func TestApplyEnvVars_Options(t *testing.T) {
 	var err error
 	_ = setExistingMigrationPath(t)
 
@@ -95,14 +96,16 @@ func TestGetOptions(t *testing.T) {
 	err = os.Setenv(envOptionIsUpgradeDisabled, "true")
 	assert.Nil(t, err)
 
-	opts, err := getOptions()
+	cfg := Configuration{}
+	err = applyEnvVars(&cfg, configurationSources{})
 	assert.Nil(t, err)
+	opts := cfg.Options
 	assert.True(t, opts.IsCreateDisabled)
 	assert.True(t, opts.IsDowngradeDisabled)
 	assert.True(t, opts.IsUpgradeDisabled)
 }
 
-func TestGetOptions_Default(t *testing.T) {
+func TestApplyEnvVars_DefaultOptions(t *testing.T) {
 	var err error
 
 	err = os.Unsetenv(envPathMigrations)
@@ -117,14 +120,16 @@ func TestGetOptions_Default(t *testing.T) {
 	err = os.Unsetenv(envOptionIsUpgradeDisabled)
 	assert.Nil(t, err)
 
-	opts, err := getOptions()
+	cfg := Configuration{}
+	err = applyEnvVars(&cfg, configurationSources{})
 	assert.Nil(t, err)
+	opts := cfg.Options
 	assert.False(t, opts.IsCreateDisabled)
 	assert.False(t, opts.IsDowngradeDisabled)
 	assert.False(t, opts.IsUpgradeDisabled)
 }
 
-func TestGetOptions_Cases(t *testing.T) {
+func TestApplyEnvVars_OptionCases(t *testing.T) {
 	setExistingMigrationPath(t)
 
 	type test struct {
@@ -150,53 +155,186 @@ func TestGetOptions_Cases(t *testing.T) {
 	for _, v := range tests {
 		err := os.Setenv(envOptionIsCreateDisabled, v.inputValue)
 		assert.Nil(t, err)
-		opts, err := getOptions()
+		cfg := Configuration{}
+		err = applyEnvVars(&cfg, configurationSources{})
 		assert.Equal(t, v.isOptionsError, err != nil)
-		assert.Equal(t, v.expectedBooleanValue, opts != nil && opts.IsCreateDisabled)
+		assert.Equal(t, v.expectedBooleanValue, err == nil && cfg.Options.IsCreateDisabled)
 	}
 
 	for _, v := range tests {
 		err := os.Setenv(envOptionIsDowngradeDisabled, v.inputValue)
 		assert.Nil(t, err)
-		opts, err := getOptions()
+		cfg := Configuration{}
+		err = applyEnvVars(&cfg, configurationSources{})
 		assert.Equal(t, v.isOptionsError, err != nil)
-		assert.Equal(t, v.expectedBooleanValue, opts != nil && opts.IsDowngradeDisabled)
+		assert.Equal(t, v.expectedBooleanValue, err == nil && cfg.Options.IsDowngradeDisabled)
 	}
 
 	for _, v := range tests {
 		err := os.Setenv(envOptionIsUpgradeDisabled, v.inputValue)
 		assert.Nil(t, err)
-		opts, err := getOptions()
+		cfg := Configuration{}
+		err = applyEnvVars(&cfg, configurationSources{})
 		assert.Equal(t, v.isOptionsError, err != nil)
-		assert.Equal(t, v.expectedBooleanValue, opts != nil && opts.IsUpgradeDisabled)
+		assert.Equal(t, v.expectedBooleanValue, err == nil && cfg.Options.IsUpgradeDisabled)
 	}
 }
 
-func TestGetBooleanOption_IsCreateDisabled(t *testing.T) {
+func TestApplyEnvVars_IsCreateDisabled(t *testing.T) {
 	tests := map[string]expectedOutput{
 		"ttruuee": {b: false, hasError: true},
 		"false":   {b: false, hasError: false},
 		"true":    {b: true, hasError: false},
 	}
-	testGetBooleanOption(t, envOptionIsCreateDisabled, tests)
+	testBooleanOptionEnvVar(t, envOptionIsCreateDisabled, tests, func(o ConfigurationOptions) bool {
+		return o.IsCreateDisabled
+	})
 }
 
-func TestGetBooleanOption_IsDowngradeDisabled(t *testing.T) {
+func TestApplyEnvVars_IsDowngradeDisabled(t *testing.T) {
 	tests := map[string]expectedOutput{
 		"ttruuee": {b: false, hasError: true},
 		"false":   {b: false, hasError: false},
 		"true":    {b: true, hasError: false},
 	}
-	testGetBooleanOption(t, envOptionIsDowngradeDisabled, tests)
+	testBooleanOptionEnvVar(t, envOptionIsDowngradeDisabled, tests, func(o ConfigurationOptions) bool {
+		return o.IsDowngradeDisabled
+	})
 }
 
-func TestGetBooleanOption_IsUpgradeDisabled(t *testing.T) {
+func TestApplyEnvVars_IsUpgradeDisabled(t *testing.T) {
 	tests := map[string]expectedOutput{
 		"ttruuee": {b: false, hasError: true},
 		"false":   {b: false, hasError: false},
 		"true":    {b: true, hasError: false},
 	}
-	testGetBooleanOption(t, envOptionIsUpgradeDisabled, tests)
+	testBooleanOptionEnvVar(t, envOptionIsUpgradeDisabled, tests, func(o ConfigurationOptions) bool {
+		return o.IsUpgradeDisabled
+	})
+}
+
+func TestResolveConfiguration_Precedence(t *testing.T) {
+	migrationsPath := setExistingMigrationPath(t)
+	unsetOptions(t)
+
+	file := writeConfigurationFile(t, `{"migrationsPath": "/from/file", "options": {"isCreateDisabled": true, "isUpgradeDisabled": true}}`)
+	err := os.Setenv(envConfigurationFile, file)
+	assert.Nil(t, err)
+	defer os.Unsetenv(envConfigurationFile)
+
+	err = os.Setenv(envOptionIsCreateDisabled, "false")
+	assert.Nil(t, err)
+	defer os.Unsetenv(envOptionIsCreateDisabled)
+
+	base := Configuration{Options: ConfigurationOptions{IsDowngradeDisabled: true}}
+	cfg, sources, err := resolveConfiguration(&base, map[string]string{"upgrade-disabled": "false"})
+	assert.Nil(t, err)
+
+	// Env overrides file
+	assert.Equal(t, migrationsPath, cfg.MigrationsPath)
+	assert.Equal(t, "env (DBSHIFT_ABS_FOLDER_MIGRATIONS)", sources.get("migrationsPath"))
+	assert.False(t, cfg.Options.IsCreateDisabled)
+
+	// Code overrides defaults
+	assert.True(t, cfg.Options.IsDowngradeDisabled)
+	assert.Equal(t, configurationSourceCode, sources.get("options.isDowngradeDisabled"))
+
+	// Flag overrides file
+	assert.False(t, cfg.Options.IsUpgradeDisabled)
+	assert.Equal(t, "flag (--upgrade-disabled)", sources.get("options.isUpgradeDisabled"))
+}
+
+func TestResolveConfiguration_File(t *testing.T) {
+	migrationsPath := setExistingMigrationPath(t)
+	err := os.Unsetenv(envPathMigrations)
+	assert.Nil(t, err)
+
+	file := writeConfigurationFile(t, `{"migrationsPath": "`+migrationsPath+`"}`)
+	cfg, sources, err := resolveConfiguration(nil, map[string]string{flagConfigurationFile: file})
+	assert.Nil(t, err)
+	assert.Equal(t, migrationsPath, cfg.MigrationsPath)
+	assert.Equal(t, "file ("+file+")", sources.get("migrationsPath"))
+	assert.Equal(t, configurationSourceDefault, sources.get("options.isCreateDisabled"))
+}
+
+func TestResolveConfiguration_BadFile(t *testing.T) {
+	setExistingMigrationPath(t)
+
+	file := writeConfigurationFile(t, `{"unknownKey": true}`)
+	_, _, err := resolveConfiguration(nil, map[string]string{flagConfigurationFile: file})
+	assert.NotNil(t, err, "expected error on unknown configuration key")
+
+	_, _, err = resolveConfiguration(nil, map[string]string{flagConfigurationFile: file + ".missing"})
+	assert.NotNil(t, err, "expected error on missing configuration file")
+}
+
+func TestParseConfigurationFlags(t *testing.T) {
+	flags, args, err := parseConfigurationFlags([]string{"--migrations", "/srv/migrations", "--create-disabled", "upgrade", "123"})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"migrations": "/srv/migrations", "create-disabled": "true"}, flags)
+	assert.Equal(t, []string{"upgrade", "123"}, args)
+
+	_, _, err = parseConfigurationFlags([]string{"--unknown-flag", "status"})
+	assert.NotNil(t, err, "expected error on unknown flag")
+}
+
+func TestGetConfigurationEntries(t *testing.T) {
+	cfg := Configuration{
+		MigrationsPath: "/srv/migrations",
+		Environments:   map[string]ConfigurationOptions{"prod": {IsCreateDisabled: true}},
+	}
+	sources := configurationSources{"migrationsPath": configurationSourceCode}
+
+	entries, err := getConfigurationEntries(cfg, sources)
+	assert.Nil(t, err)
+
+	entriesByKey := map[string]configurationEntry{}
+	for _, e := range entries {
+		entriesByKey[e.Key] = e
+	}
+
+	assert.Equal(t, configurationEntry{Key: "migrationsPath", Value: "/srv/migrations", Source: configurationSourceCode}, entriesByKey["migrationsPath"])
+	assert.Equal(t, configurationEntry{Key: "options.isCreateDisabled", Value: "false", Source: configurationSourceDefault}, entriesByKey["options.isCreateDisabled"])
+	assert.Equal(t, configurationEntry{Key: "environments.prod.isCreateDisabled", Value: "true", Source: configurationSourceDefault}, entriesByKey["environments.prod.isCreateDisabled"])
+}
+
+func TestConfiguration_GetOptions(t *testing.T) {
+	cfg := Configuration{
+		Options: ConfigurationOptions{IsUpgradeDisabled: true},
+		Environments: map[string]ConfigurationOptions{
+			"dev":  {},
+			"prod": {IsCreateDisabled: true, IsDowngradeDisabled: true, IsConfirmationRequired: true, ConfirmationThreshold: 5},
+		},
+	}
+	cfg.Options.ConfirmationThreshold = 10
+
+	assert.Equal(t, ConfigurationOptions{IsUpgradeDisabled: true, ConfirmationThreshold: 10}, cfg.getOptions())
+
+	cfg.Environment = "dev"
+	assert.Equal(t, ConfigurationOptions{IsUpgradeDisabled: true, ConfirmationThreshold: 10}, cfg.getOptions())
+
+	cfg.Environment = "prod"
+	assert.Equal(t, ConfigurationOptions{
+		IsCreateDisabled:       true,
+		IsDowngradeDisabled:    true,
+		IsUpgradeDisabled:      true,
+		IsConfirmationRequired: true,
+		ConfirmationThreshold:  5,
+	}, cfg.getOptions())
+}
+
+func TestCheckConfiguration_Environment(t *testing.T) {
+	migrationsPath := setExistingMigrationPath(t)
+
+	cfg := Configuration{
+		MigrationsPath: migrationsPath,
+		Environment:    "prod",
+		Environments:   map[string]ConfigurationOptions{"dev": {}},
+	}
+	assert.NotNil(t, checkConfiguration(cfg), "expected error on undefined environment")
+
+	cfg.Environment = "dev"
+	assert.Nil(t, checkConfiguration(cfg))
 }
 
 func TestGetEnvVar(t *testing.T) {
@@ -229,6 +367,22 @@ func setUnexistingMigrationPath(t *testing.T) string {
 	return migrationsPath
 }
 
+func writeConfigurationFile(t *testing.T, content string) string {
+	dir, err := ioutil.TempDir("", "dbshift")
+	assert.Nil(t, err)
+	location := filepath.Join(dir, configurationFileName)
+	err = ioutil.WriteFile(location, []byte(content), 0664)
+	assert.Nil(t, err)
+	return location
+}
+
+func unsetOptions(t *testing.T) {
+	for _, envKey := range []string{envOptionIsCreateDisabled, envOptionIsDowngradeDisabled, envOptionIsUpgradeDisabled} {
+		err := os.Unsetenv(envKey)
+		assert.Nil(t, err)
+	}
+}
+
 func setInvalidOption(t *testing.T) {
 	err := os.Setenv(envOptionIsUpgradeDisabled, "true!")
 	assert.Nil(t, err)
@@ -244,12 +398,15 @@ type expectedOutput struct {
 	hasError bool
 }
 
-func testGetBooleanOption(t *testing.T, envKey string, tests map[string]expectedOutput) {
+// testBooleanOptionEnvVar applies the environment variable of a boolean option and checks the resulting option.
+func testBooleanOptionEnvVar(t *testing.T, envKey string, tests map[string]expectedOutput, option func(ConfigurationOptions) bool) {
+	defer os.Unsetenv(envKey)
 	for envValue, expectedOutput := range tests {
 		err := os.Setenv(envKey, envValue)
 		assert.Nil(t, err)
-		b, err := getBooleanOption(envKey)
-		assert.Equal(t, expectedOutput.b, b, "expected same boolean value")
+		cfg := Configuration{}
+		err = applyEnvVars(&cfg, configurationSources{})
+		assert.Equal(t, expectedOutput.b, option(cfg.Options), "expected same boolean value")
 		assert.Equal(t, expectedOutput.hasError, err != nil, "expected same error value")
 	}
 }
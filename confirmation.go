@@ -0,0 +1,57 @@
+package dbshiftcore
+
+import (
+	"fmt"
+	"strings"
+)
+
+const flagYes = "yes"
+
+// isConfirmationRequired returns true when executing the migrations must be approved by the user.
+// Downgrades always require it, upgrades only when they reach the configured threshold or when a migration requires it.
+func (c *cmd) isConfirmationRequired(t migrationType, migrationList []Migration) bool {
+	if len(migrationList) == 0 {
+		return false
+	}
+
+	options := c.cfg.getOptions()
+	if options.IsConfirmationRequired || t == migrationTypeDowngrade {
+		return true
+	}
+
+	for _, m := range migrationList {
+		if m.Directives.IsConfirmationRequired {
+			return true
+		}
+	}
+
+	return options.ConfirmationThreshold > 0 && len(migrationList) >= options.ConfirmationThreshold
+}
+
+// confirm lists the migrations about to run and asks the user to approve the action.
+func (c *cmd) confirm(readLine func() string, action string, migrationList []Migration) bool {
+	if len(migrationList) > 0 {
+		c.printMigrationList(action, migrationList)
+	}
+
+	if c.cfg.Environment != "" {
+		c.printf("Confirm %s on environment %s? [y/N] ", action, c.cfg.Environment)
+	} else {
+		c.printf("Confirm %s? [y/N] ", action)
+	}
+
+	answer := strings.ToLower(strings.TrimSpace(readLine()))
+	return answer == "y" || answer == "yes"
+}
+
+func newNotConfirmedError(action string) error {
+	return fmt.Errorf("%s has not been confirmed: use --%s for non-interactive usage", action, flagYes)
+}
+
+// printMigrationList prints the migrations about to run by the action.
+func (c *cmd) printMigrationList(action string, migrationList []Migration) {
+	c.printf("Migrations to %s\n", action)
+	for _, m := range migrationList {
+		c.printf("%s\n", m.Name)
+	}
+}
@@ -0,0 +1,45 @@
+package dbshiftcore
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestCmd_IsConfirmationRequired(t *testing.T) {
+	confirmationCmd := &cmd{cfg: Configuration{Options: ConfigurationOptions{ConfirmationThreshold: 3}}}
+
+	assert.False(t, confirmationCmd.isConfirmationRequired(migrationTypeDowngrade, make([]Migration, 0)), "expected no confirmation without migrations")
+	assert.True(t, confirmationCmd.isConfirmationRequired(migrationTypeDowngrade, make([]Migration, 1)), "expected confirmation on downgrade")
+	assert.False(t, confirmationCmd.isConfirmationRequired(migrationTypeUpgrade, make([]Migration, 2)), "expected no confirmation under threshold")
+	assert.True(t, confirmationCmd.isConfirmationRequired(migrationTypeUpgrade, make([]Migration, 3)), "expected confirmation at threshold")
+
+	confirmationCmd.cfg.Options.ConfirmationThreshold = 0
+	assert.False(t, confirmationCmd.isConfirmationRequired(migrationTypeUpgrade, make([]Migration, 100)), "expected no confirmation without threshold")
+
+	migrationList := []Migration{{Directives: MigrationDirectives{IsConfirmationRequired: true}}}
+	assert.True(t, confirmationCmd.isConfirmationRequired(migrationTypeUpgrade, migrationList), "expected confirmation required by migration")
+
+	confirmationCmd.cfg.Options.IsConfirmationRequired = true
+	assert.True(t, confirmationCmd.isConfirmationRequired(migrationTypeUpgrade, make([]Migration, 1)), "expected confirmation required by options")
+}
+
+func TestCmd_Confirm(t *testing.T) {
+	var out bytes.Buffer
+	confirmationCmd := &cmd{out: &out}
+	migrationList := []Migration{newMigration("123", "hello-world", migrationTypeDowngrade, "sql")}
+
+	answers := map[string]bool{
+		"y":     true,
+		"YES\n": true,
+		"":      false,
+		"n":     false,
+		"maybe": false,
+	}
+
+	for answer, expected := range answers {
+		readLine := func() string { return answer }
+		assert.Equal(t, expected, confirmationCmd.confirm(readLine, "downgrade", migrationList), "expected confirmation result for %q", answer)
+	}
+	assert.Contains(t, out.String(), "Migrations to downgrade\n123-hello-world.down.sql\nConfirm downgrade? [y/N] ")
+}
@@ -0,0 +1,115 @@
+package dbshiftcore
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/user"
+	"path/filepath"
+)
+
+const (
+	flagTemplate         = "template"
+	defaultTemplateName  = "default"
+	defaultTemplatesPath = ".templates"
+)
+
+// MigrationTemplate is the content of the upgrade and downgrade files written by create.
+// Placeholders are Go templates: {{.name}}, {{.version}}, {{.author}} and {{.date}}.
+type MigrationTemplate struct {
+	Upgrade   string
+	Downgrade string
+}
+
+// iTemplateDatabase is an optional capability of the database implementation.
+// GetTemplates returns the templates of the client by name, overridden by the project templates with the same name.
+type iTemplateDatabase interface {
+	GetTemplates() map[string]MigrationTemplate
+}
+
+// getTemplatesPath returns the folder of the project templates, by default .templates inside the first migrations location.
+func (c *cmd) getTemplatesPath() string {
+	if c.cfg.TemplatesPath != "" {
+		return c.cfg.TemplatesPath
+	}
+	return filepath.Join(c.getMigrationsPath(), defaultTemplatesPath)
+}
+
+// getTemplate returns the template with name, looking for the project files <name>.up.<ext> and <name>.down.<ext> first,
+// then for the client templates. A missing default template means empty files.
+func (c *cmd) getTemplate(name string) (MigrationTemplate, error) {
+	var t MigrationTemplate
+	var isFound bool
+
+	for _, file := range []struct {
+		migrationType migrationType
+		content       *string
+	}{
+		{migrationTypeUpgrade, &t.Upgrade},
+		{migrationTypeDowngrade, &t.Downgrade},
+	} {
+		location := filepath.Join(c.getTemplatesPath(), fmt.Sprintf("%s.%s.%s", name, file.migrationType, c.db.GetExtension()))
+		data, err := ioutil.ReadFile(location)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return t, err
+		}
+		*file.content, isFound = string(data), true
+	}
+
+	if isFound {
+		return t, nil
+	}
+
+	if templateDb, ok := c.db.(iTemplateDatabase); ok {
+		if t, ok := templateDb.GetTemplates()[name]; ok {
+			return t, nil
+		}
+	}
+
+	if name == defaultTemplateName {
+		return t, nil
+	}
+
+	return t, fmt.Errorf("template %s not found in %s nor in the database implementation", name, c.getTemplatesPath())
+}
+
+// renderTemplate fills the placeholders of the template for a new migration.
+func (c *cmd) renderTemplate(templateName string, migrationName string, version string) (MigrationTemplate, error) {
+	t, err := c.getTemplate(templateName)
+	if err != nil {
+		return t, err
+	}
+
+	data := map[string]string{
+		"name":    migrationName,
+		"version": version,
+		"author":  c.getAuthor(),
+		"date":    c.clock().UTC().Format("2006-01-02"),
+	}
+
+	upgrade, err := renderMigration(templateName+" upgrade", []byte(t.Upgrade), data)
+	if err != nil {
+		return t, err
+	}
+
+	downgrade, err := renderMigration(templateName+" downgrade", []byte(t.Downgrade), data)
+	if err != nil {
+		return t, err
+	}
+
+	return MigrationTemplate{Upgrade: string(upgrade), Downgrade: string(downgrade)}, nil
+}
+
+// getAuthor returns the configured author, otherwise the current OS user.
+func (c *cmd) getAuthor() string {
+	if c.cfg.Author != "" {
+		return c.cfg.Author
+	}
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return ""
+}
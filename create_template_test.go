@@ -0,0 +1,76 @@
+package dbshiftcore
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCmd_CreateTemplate(t *testing.T) {
+	err := setConfigurationWithCustomOptions("false", "false", "false")
+	assert.Nil(t, err, "expected nil error on set configuration with custom options")
+	defer unsetConfiguration(t, "DBSHIFT_DUMMY_TEMPLATE_STATUS")
+
+	db := &templateDbImplementation{
+		dummyDbImplementation: dummyDbImplementation{envStatus: "DBSHIFT_DUMMY_TEMPLATE_STATUS"},
+		templates: map[string]MigrationTemplate{
+			"index": {Upgrade: "CREATE INDEX {{.name}};", Downgrade: "DROP INDEX {{.name}};"},
+			"table": {Upgrade: "overridden by the project"},
+		},
+	}
+	templateCmd, err := NewCmdWithConfiguration(db, Configuration{Author: "jane"})
+	assert.Nil(t, err, "expected nil error")
+
+	migrationsPath := templateCmd.getMigrationsPath()
+	templatesPath := filepath.Join(migrationsPath, defaultTemplatesPath)
+	assert.Equal(t, templatesPath, templateCmd.getTemplatesPath())
+	assert.Nil(t, os.MkdirAll(templatesPath, 0775))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(templatesPath, "table.up.txt"), []byte("-- {{.version}} {{.name}} by {{.author}}\nCREATE TABLE {{.name}} ();"), 0664))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(templatesPath, "table.down.txt"), []byte("-- TODO: write your down\n"), 0664))
+
+	// Missing default template means empty files
+	template, err := templateCmd.renderTemplate(defaultTemplateName, "users", "20200101000000")
+	assert.Nil(t, err)
+	assert.Equal(t, MigrationTemplate{}, template)
+
+	// Project templates override the database ones
+	template, err = templateCmd.renderTemplate("table", "users", "20200101000000")
+	assert.Nil(t, err)
+	assert.Equal(t, MigrationTemplate{Upgrade: "-- 20200101000000 users by jane\nCREATE TABLE users ();", Downgrade: "-- TODO: write your down\n"}, template)
+
+	template, err = templateCmd.renderTemplate("index", "users_email", "20200101000000")
+	assert.Nil(t, err)
+	assert.Equal(t, MigrationTemplate{Upgrade: "CREATE INDEX users_email;", Downgrade: "DROP INDEX users_email;"}, template)
+
+	_, err = templateCmd.renderTemplate("data", "users", "20200101000000")
+	assert.NotNil(t, err, "expected error on missing template")
+
+	// Undefined placeholders are an error
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(templatesPath, "bad.up.txt"), []byte("{{.ticket}}"), 0664))
+	_, err = templateCmd.renderTemplate("bad", "users", "20200101000000")
+	assert.NotNil(t, err, "expected error on undefined placeholder")
+
+	// Templates are not migrations
+	assert.Nil(t, templateCmd.create("users", "table"))
+	migrationList, err := templateCmd.getUpgradePlan("")
+	assert.Nil(t, err)
+	assert.Len(t, migrationList, 1)
+
+	data, err := ioutil.ReadFile(migrationList[0].getLocation(migrationsPath))
+	assert.Nil(t, err)
+	assert.True(t, strings.HasSuffix(string(data), "users by jane\nCREATE TABLE users ();"))
+}
+
+// Helpers
+
+type templateDbImplementation struct {
+	dummyDbImplementation
+	templates map[string]MigrationTemplate
+}
+
+func (db *templateDbImplementation) GetTemplates() map[string]MigrationTemplate {
+	return db.templates
+}
@@ -0,0 +1,284 @@
+package dbshiftcore
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// DependencyError is returned when the migrations dependencies cannot be satisfied by a plan.
+type DependencyError struct {
+	Migration Migration
+	Reason    string
+}
+
+func (e *DependencyError) Error() string {
+	return fmt.Sprintf("migration %s: %s", e.Migration.Name, e.Reason)
+}
+
+// getDependencyGraph returns the upgrades by version, checking that every dependency exists and that there are no cycles.
+func (c *cmd) getDependencyGraph() (map[string]Migration, error) {
+	upgradeList, err := c.getMigrations(Status{}, "", func(m Migration, status Status, toInclusiveVersion string) bool {
+		return m.Type == migrationTypeUpgrade
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	graph := map[string]Migration{}
+	for _, m := range upgradeList {
+		graph[m.Version] = m
+	}
+
+	if err := checkDependencies(graph); err != nil {
+		return nil, err
+	}
+
+	return graph, nil
+}
+
+// checkDependencies returns an error on unknown dependencies and on cycles.
+func checkDependencies(graph map[string]Migration) error {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+
+	state := map[string]int{}
+	var path []string
+
+	var visit func(version string) error
+	visit = func(version string) error {
+		switch state[version] {
+		case visited:
+			return nil
+		case visiting:
+			return fmt.Errorf("dependency cycle %s -> %s", strings.Join(path, " -> "), version)
+		}
+
+		state[version] = visiting
+		path = append(path, version)
+
+		m := graph[version]
+		for _, dependency := range m.Directives.Dependencies {
+			if _, ok := graph[dependency]; !ok {
+				return &DependencyError{Migration: m, Reason: fmt.Sprintf("unknown dependency %s", dependency)}
+			}
+			if err := visit(dependency); err != nil {
+				return err
+			}
+		}
+
+		path = path[:len(path)-1]
+		state[version] = visited
+		return nil
+	}
+
+	for _, version := range getGraphVersions(graph) {
+		if err := visit(version); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// sortByDependencies returns the migrations in topological order, every migration after its dependencies.
+// Independent migrations keep the version order. Dependencies outside the list are ignored.
+func sortByDependencies(migrationList []Migration, graph map[string]Migration) []Migration {
+	pending := map[string]Migration{}
+	for _, m := range migrationList {
+		pending[m.Version] = m
+	}
+
+	var sorted []Migration
+	for len(pending) > 0 {
+		var ready []string
+		for version := range pending {
+			if !hasPendingDependency(graph[version], pending) {
+				ready = append(ready, version)
+			}
+		}
+
+		// Cycles are rejected by the graph check: the lowest ready version is next
+		sort.Strings(ready)
+		sorted = append(sorted, pending[ready[0]])
+		delete(pending, ready[0])
+	}
+
+	return sorted
+}
+
+func hasPendingDependency(m Migration, pending map[string]Migration) bool {
+	for _, dependency := range m.Directives.Dependencies {
+		if _, ok := pending[dependency]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// sortUpgradePlan sorts the upgrades by dependencies, checking that every dependency is applied or part of the plan.
+func (c *cmd) sortUpgradePlan(status Status, migrationList []Migration) ([]Migration, error) {
+	graph, err := c.getDependencyGraph()
+	if err != nil {
+		return nil, err
+	}
+
+	applied, err := c.getAppliedVersions(status)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := map[string]bool{}
+	for _, m := range migrationList {
+		plan[m.Version] = true
+	}
+
+	for _, m := range migrationList {
+		for _, dependency := range graph[m.Version].Directives.Dependencies {
+			if !plan[dependency] && !c.isApplied(graph[dependency], status, applied) {
+				return nil, &DependencyError{Migration: m, Reason: fmt.Sprintf("dependency %s is neither applied nor part of the plan", dependency)}
+			}
+		}
+	}
+
+	sorted := sortByDependencies(migrationList, graph)
+
+	// Without history the status is the last executed migration, so the newest one must be executed last
+	if n := len(sorted); applied == nil && n > 0 && sorted[n-1].Version != migrationList[n-1].Version {
+		return nil, &DependencyError{Migration: migrationList[n-1], Reason: "the newest migration of the plan is a dependency of an older one and cannot be executed last"}
+	}
+
+	return sorted, nil
+}
+
+// sortDowngradePlan sorts the downgrades in the reverse order of the dependencies,
+// rejecting the plan when a migration still applied after it depends on a downgraded one.
+func (c *cmd) sortDowngradePlan(status Status, migrationList []Migration) ([]Migration, error) {
+	graph, err := c.getDependencyGraph()
+	if err != nil {
+		return nil, err
+	}
+
+	applied, err := c.getAppliedVersions(status)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := map[string]bool{}
+	for _, m := range migrationList {
+		plan[m.Version] = true
+	}
+
+	for _, version := range getGraphVersions(graph) {
+		m := graph[version]
+		if plan[m.Version] || !c.isApplied(m, status, applied) {
+			continue
+		}
+		for _, dependency := range m.Directives.Dependencies {
+			if plan[dependency] {
+				return nil, &DependencyError{Migration: m, Reason: fmt.Sprintf("it depends on %s and would remain applied", dependency)}
+			}
+		}
+	}
+
+	// Reverse topological order
+	sorted := sortByDependencies(migrationList, graph)
+	for i, j := 0, len(sorted)-1; i < j; i, j = i+1, j-1 {
+		sorted[i], sorted[j] = sorted[j], sorted[i]
+	}
+
+	// Without history the status is the last executed migration, so the oldest one must be executed last
+	if n := len(sorted); applied == nil && n > 0 && sorted[n-1].Version != migrationList[n-1].Version {
+		return nil, &DependencyError{Migration: migrationList[n-1], Reason: "the oldest migration of the plan depends on a newer one and cannot be downgraded last"}
+	}
+
+	return sorted, nil
+}
+
+// isApplied returns true when the upgrade is applied, according to the history when available or else to the status,
+// and allowed in the active environment.
+func (c *cmd) isApplied(upgrade Migration, status Status, applied map[string]bool) bool {
+	if upgrade.getSkipReason(c.cfg.Environment) != "" {
+		return false
+	}
+	if applied != nil {
+		return applied[upgrade.Version]
+	}
+	return isDowngradable(upgrade.getCounterpart(), status, "")
+}
+
+func getGraphVersions(graph map[string]Migration) []string {
+	versions := make([]string, 0, len(graph))
+	for version := range graph {
+		versions = append(versions, version)
+	}
+	sort.Strings(versions)
+	return versions
+}
+
+// getAppliedVersions returns the versions of the applied upgrades according to the history, or nil without history.
+// Upgrades older than the history are taken from the status, since they may have been applied before it was recorded.
+// Unlike the status, the history tells apart an upgrade older than the last executed one that was never applied,
+// e.g. merged after newer ones: it is pending instead of taken as applied.
+func (c *cmd) getAppliedVersions(status Status) (map[string]bool, error) {
+	historyDb, ok := c.db.(iHistoryDatabase)
+	if !ok {
+		return nil, nil
+	}
+
+	history, err := historyDb.GetHistory()
+	if err != nil || len(history) == 0 {
+		return nil, err
+	}
+
+	oldestVersion := history[0].Version
+	for _, m := range history {
+		if m.Version < oldestVersion {
+			oldestVersion = m.Version
+		}
+	}
+
+	upgradeList, err := c.getMigrations(status, "", func(m Migration, status Status, toInclusiveVersion string) bool {
+		return m.Type == migrationTypeUpgrade && m.Version < oldestVersion && isDowngradable(m.getCounterpart(), status, "")
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	applied := map[string]bool{}
+	for _, m := range upgradeList {
+		applied[m.Version] = true
+	}
+	for version := range getAppliedUpgrades(history) {
+		applied[version] = true
+	}
+
+	return applied, nil
+}
+
+// getUpgradableFilter returns the filter of the upgrades to execute, according to the history when available.
+func (c *cmd) getUpgradableFilter(status Status) (migrationFilterFn, error) {
+	applied, err := c.getAppliedVersions(status)
+	if err != nil || applied == nil {
+		return isUpgradable, err
+	}
+
+	return func(m Migration, status Status, toInclusiveVersion string) bool {
+		return m.Type == migrationTypeUpgrade && !applied[m.Version] && (toInclusiveVersion == "" || m.Version <= toInclusiveVersion)
+	}, nil
+}
+
+// getDowngradableFilter returns the filter of the downgrades to execute, according to the history when available.
+func (c *cmd) getDowngradableFilter(status Status) (migrationFilterFn, error) {
+	applied, err := c.getAppliedVersions(status)
+	if err != nil || applied == nil {
+		return isDowngradable, err
+	}
+
+	return func(m Migration, status Status, toInclusiveVersion string) bool {
+		return m.Type == migrationTypeDowngrade && applied[m.Version] && (toInclusiveVersion == "" || m.Version >= toInclusiveVersion)
+	}, nil
+}
@@ -0,0 +1,145 @@
+package dbshiftcore
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"testing"
+)
+
+func TestCheckDependencies(t *testing.T) {
+	graph := map[string]Migration{
+		"1": {Version: "1"},
+		"2": {Version: "2", Directives: MigrationDirectives{Dependencies: []string{"1", "3"}}},
+		"3": {Version: "3", Directives: MigrationDirectives{Dependencies: []string{"1"}}},
+	}
+	assert.Nil(t, checkDependencies(graph))
+
+	graph["1"] = Migration{Version: "1", Directives: MigrationDirectives{Dependencies: []string{"2"}}}
+	err := checkDependencies(graph)
+	assert.NotNil(t, err, "expected error on cycle")
+	assert.Equal(t, "dependency cycle 1 -> 2 -> 1", err.Error())
+
+	graph["1"] = Migration{Version: "1", Name: "1-first.up.sql", Directives: MigrationDirectives{Dependencies: []string{"0"}}}
+	err = checkDependencies(graph)
+	assert.IsType(t, &DependencyError{}, err)
+	assert.Equal(t, "migration 1-first.up.sql: unknown dependency 0", err.Error())
+}
+
+func TestSortByDependencies(t *testing.T) {
+	graph := map[string]Migration{
+		"1": {Version: "1"},
+		"2": {Version: "2", Directives: MigrationDirectives{Dependencies: []string{"3"}}},
+		"3": {Version: "3"},
+		"4": {Version: "4", Directives: MigrationDirectives{Dependencies: []string{"0"}}},
+	}
+	migrationList := []Migration{graph["1"], graph["2"], graph["3"], graph["4"]}
+
+	assert.Equal(t, []string{"1", "3", "2", "4"}, getVersions(sortByDependencies(migrationList, graph)))
+	assert.Equal(t, []string{"2", "4"}, getVersions(sortByDependencies([]Migration{graph["4"], graph["2"]}, graph)))
+}
+
+func TestCmd_Dependencies(t *testing.T) {
+	err := setConfigurationWithCustomOptions("false", "false", "false")
+	assert.Nil(t, err, "expected nil error on set configuration with custom options")
+	defer unsetConfiguration(t, "DBSHIFT_DUMMY_DEPENDENCY_STATUS")
+
+	dependencyCmd, err := NewCmd(&dummyDbImplementation{envStatus: "DBSHIFT_DUMMY_DEPENDENCY_STATUS"})
+	assert.Nil(t, err, "expected nil error")
+
+	migrationsPath := os.Getenv(envPathMigrations)
+	writeMigrationFiles(t, migrationsPath, "20200101000000", "users")
+	writeMigrationFiles(t, migrationsPath, "20200102000000", "orders")
+	writeMigrationFile(t, migrationsPath, newMigration("20200102000000", "orders", migrationTypeUpgrade, "txt"), "-- dbshift:depends=20200103000000\n")
+	writeMigrationFiles(t, migrationsPath, "20200103000000", "products")
+	writeMigrationFiles(t, migrationsPath, "20200104000000", "invoices")
+
+	// Dependencies come first, independent migrations keep the version order
+	migrationList, err := dependencyCmd.getUpgradePlan("")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"20200101000000", "20200103000000", "20200102000000", "20200104000000"}, getVersions(migrationList))
+
+	// A dependency outside the plan is not applied
+	_, err = dependencyCmd.getUpgradePlan("20200102000000")
+	assert.IsType(t, &DependencyError{}, err)
+
+	assert.Nil(t, dependencyCmd.execMigrations(migrationList))
+
+	// A downgrade cannot leave a dependent migration applied
+	_, err = dependencyCmd.getDowngradePlan("20200103000000", false)
+	assert.IsType(t, &DependencyError{}, err)
+
+	migrationList, err = dependencyCmd.getDowngradePlan("", false)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"20200104000000", "20200102000000", "20200103000000", "20200101000000"}, getVersions(migrationList))
+
+	// Cycles are rejected
+	writeMigrationFile(t, migrationsPath, newMigration("20200103000000", "products", migrationTypeUpgrade, "txt"), "-- dbshift:depends=20200102000000\n")
+	_, err = dependencyCmd.getDowngradePlan("", false)
+	assert.NotNil(t, err, "expected error on dependency cycle")
+}
+
+func TestCmd_Dependencies_OutOfOrder(t *testing.T) {
+	err := setConfigurationWithCustomOptions("false", "false", "false")
+	assert.Nil(t, err, "expected nil error on set configuration with custom options")
+	defer unsetConfiguration(t, "DBSHIFT_DUMMY_DEPENDENCY_STATUS")
+
+	dependencyCmd, err := NewCmd(&dummyDbImplementation{envStatus: "DBSHIFT_DUMMY_DEPENDENCY_STATUS"})
+	assert.Nil(t, err, "expected nil error")
+
+	migrationsPath := os.Getenv(envPathMigrations)
+	writeMigrationFiles(t, migrationsPath, "20200101000000", "users")
+	writeMigrationFiles(t, migrationsPath, "20200103000000", "products")
+	assert.Nil(t, dependencyCmd.upgrade(""))
+
+	// Merged after newer migrations were applied, its dependency is satisfied
+	writeMigrationFiles(t, migrationsPath, "20200102000000", "orders")
+	migrationList, err := dependencyCmd.getUpgradePlan("")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"20200102000000"}, getVersions(migrationList))
+	assert.Nil(t, dependencyCmd.status())
+	assert.Nil(t, dependencyCmd.execMigrations(migrationList))
+
+	// The history, not the status left at the older migration, tells what is applied
+	migrationList, err = dependencyCmd.getUpgradePlan("")
+	assert.Nil(t, err)
+	assert.Empty(t, migrationList)
+
+	migrationList, err = dependencyCmd.getDowngradePlan("", false)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"20200103000000", "20200102000000", "20200101000000"}, getVersions(migrationList))
+
+	// The newest migration of the plan may be a dependency of an older one
+	writeMigrationFiles(t, migrationsPath, "20200104000000", "invoices")
+	writeMigrationFiles(t, migrationsPath, "20200105000000", "payments")
+	writeMigrationFile(t, migrationsPath, newMigration("20200104000000", "invoices", migrationTypeUpgrade, "txt"), "-- dbshift:depends=20200105000000\n")
+	migrationList, err = dependencyCmd.getUpgradePlan("")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"20200105000000", "20200104000000"}, getVersions(migrationList))
+}
+
+func TestCmd_Dependencies_NoHistory(t *testing.T) {
+	err := setConfigurationWithCustomOptions("false", "false", "false")
+	assert.Nil(t, err, "expected nil error on set configuration with custom options")
+	defer unsetConfiguration(t, "DBSHIFT_DUMMY_DEPENDENCY_STATUS")
+
+	dependencyCmd, err := NewCmd(noHistoryDbImplementation{&dummyDbImplementation{envStatus: "DBSHIFT_DUMMY_DEPENDENCY_STATUS"}})
+	assert.Nil(t, err, "expected nil error")
+
+	migrationsPath := os.Getenv(envPathMigrations)
+	writeMigrationFiles(t, migrationsPath, "20200101000000", "users")
+	writeMigrationFiles(t, migrationsPath, "20200103000000", "products")
+	assert.Nil(t, dependencyCmd.upgrade(""))
+
+	// Without history, an older migration merged later is taken as applied
+	writeMigrationFiles(t, migrationsPath, "20200102000000", "orders")
+	migrationList, err := dependencyCmd.getUpgradePlan("")
+	assert.Nil(t, err)
+	assert.Empty(t, migrationList)
+
+	// The status must end at the newest migration of the plan
+	writeMigrationFiles(t, migrationsPath, "20200104000000", "invoices")
+	writeMigrationFiles(t, migrationsPath, "20200105000000", "payments")
+	writeMigrationFile(t, migrationsPath, newMigration("20200104000000", "invoices", migrationTypeUpgrade, "txt"), "-- dbshift:depends=20200105000000\n")
+	_, err = dependencyCmd.getUpgradePlan("")
+	assert.IsType(t, &DependencyError{}, err)
+}
@@ -0,0 +1,141 @@
+package dbshiftcore
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+const directivePrefix = "dbshift:"
+
+// MigrationDirectives are the per-migration settings declared in the header comments of the migration file,
+// e.g. "-- dbshift:no-transaction" or "-- dbshift:timeout=5m env=dev,ci".
+// Dependencies are the versions that must be applied before the migration.
+type MigrationDirectives struct {
+	IsTransactionDisabled  bool
+	Timeout                time.Duration
+	IsIrreversible         bool
+	IsConfirmationRequired bool
+	Environments           []string
+	Phase                  string
+	Dependencies           []string
+	LintIgnoredRules       []string
+}
+
+// iDirectivesDatabase is an optional capability of the database implementation.
+// SetDirectives is called before the execution of every migration, e.g. to skip the transaction or to set a timeout.
+type iDirectivesDatabase interface {
+	SetDirectives(directives MigrationDirectives) error
+}
+
+var migrationDirectiveParsers = map[string]func(d *MigrationDirectives, value string) error{
+	"no-transaction": func(d *MigrationDirectives, value string) error {
+		d.IsTransactionDisabled = true
+		return checkEmptyDirectiveValue(value)
+	},
+	"timeout": func(d *MigrationDirectives, value string) (err error) {
+		d.Timeout, err = time.ParseDuration(value)
+		return err
+	},
+	"irreversible": func(d *MigrationDirectives, value string) error {
+		d.IsIrreversible = true
+		return checkEmptyDirectiveValue(value)
+	},
+	"confirm": func(d *MigrationDirectives, value string) error {
+		d.IsConfirmationRequired = true
+		return checkEmptyDirectiveValue(value)
+	},
+	"env": func(d *MigrationDirectives, value string) error {
+		if value == "" {
+			return fmt.Errorf("missing environments")
+		}
+		d.Environments = strings.Split(value, ",")
+		return nil
+	},
+	"depends": func(d *MigrationDirectives, value string) error {
+		if value == "" {
+			return fmt.Errorf("missing versions")
+		}
+		d.Dependencies = strings.Split(value, ",")
+		return nil
+	},
+	"lint-ignore": func(d *MigrationDirectives, value string) error {
+		if value == "" {
+			return fmt.Errorf("missing rules")
+		}
+		d.LintIgnoredRules = strings.Split(value, ",")
+		return nil
+	},
+	"phase": func(d *MigrationDirectives, value string) error {
+		d.Phase = value
+		return checkPhase(value)
+	},
+}
+
+func checkEmptyDirectiveValue(value string) error {
+	if value != "" {
+		return fmt.Errorf("unexpected value %s", value)
+	}
+	return nil
+}
+
+// isEnvironmentAllowed returns true when the directives allow the migration to run in the given environment.
+func (d MigrationDirectives) isEnvironmentAllowed(environment string) bool {
+	return len(d.Environments) == 0 || containsString(d.Environments, environment)
+}
+
+func readDirectives(location string) (MigrationDirectives, error) {
+	f, err := os.Open(location)
+	if err != nil {
+		return MigrationDirectives{}, err
+	}
+	defer f.Close()
+
+	return parseDirectives(f)
+}
+
+// parseDirectives parses the directives of the header, made of the comments at the top of the migration.
+// An unknown directive is an error.
+func parseDirectives(r io.Reader) (MigrationDirectives, error) {
+	var directives MigrationDirectives
+
+	scanner := bufio.NewScanner(r)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		// The header ends at the first statement
+		if !strings.HasPrefix(line, "--") {
+			break
+		}
+
+		comment := strings.TrimSpace(strings.TrimPrefix(line, "--"))
+		if !strings.HasPrefix(comment, directivePrefix) {
+			continue
+		}
+
+		for _, field := range strings.Fields(strings.TrimPrefix(comment, directivePrefix)) {
+			kv := strings.SplitN(field, "=", 2)
+			parse, ok := migrationDirectiveParsers[kv[0]]
+			if !ok {
+				return directives, fmt.Errorf("unknown directive %s at line %d", kv[0], lineNumber)
+			}
+
+			var value string
+			if len(kv) == 2 {
+				value = kv[1]
+			}
+
+			if err := parse(&directives, value); err != nil {
+				return directives, fmt.Errorf("bad directive %s at line %d: %s", kv[0], lineNumber, err)
+			}
+		}
+	}
+
+	return directives, scanner.Err()
+}
@@ -0,0 +1,114 @@
+package dbshiftcore
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseDirectives(t *testing.T) {
+	header := `
+-- Ticket: ABC-123
+-- dbshift:no-transaction timeout=5m
+-- dbshift:irreversible
+--dbshift:confirm
+-- dbshift:env=dev,ci phase=post
+-- dbshift:depends=20200101000000,20200102000000
+CREATE INDEX CONCURRENTLY idx ON greetings (description);
+-- dbshift:unknown-after-header
+`
+
+	directives, err := parseDirectives(strings.NewReader(header))
+	assert.Nil(t, err)
+	assert.Equal(t, MigrationDirectives{
+		IsTransactionDisabled:  true,
+		Timeout:                5 * time.Minute,
+		IsIrreversible:         true,
+		IsConfirmationRequired: true,
+		Environments:           []string{"dev", "ci"},
+		Phase:                  phasePost,
+		Dependencies:           []string{"20200101000000", "20200102000000"},
+	}, directives)
+}
+
+func TestParseDirectives_Errors(t *testing.T) {
+	inputs := []string{
+		"-- dbshift:no-transactoin",
+		"-- dbshift:timeout=soon",
+		"-- dbshift:irreversible=false",
+		"-- dbshift:env",
+		"-- dbshift:phase=during",
+		"-- dbshift:depends",
+	}
+
+	for _, input := range inputs {
+		_, err := parseDirectives(strings.NewReader(input))
+		assert.NotNil(t, err, "expected error parsing %q", input)
+	}
+}
+
+func TestMigrationDirectives_IsEnvironmentAllowed(t *testing.T) {
+	assert.True(t, MigrationDirectives{}.isEnvironmentAllowed(""))
+	assert.True(t, MigrationDirectives{}.isEnvironmentAllowed("prod"))
+	assert.True(t, MigrationDirectives{Environments: []string{"dev", "ci"}}.isEnvironmentAllowed("ci"))
+	assert.False(t, MigrationDirectives{Environments: []string{"dev", "ci"}}.isEnvironmentAllowed("prod"))
+	assert.False(t, MigrationDirectives{Environments: []string{"dev"}}.isEnvironmentAllowed(""))
+}
+
+func TestCmd_Directives(t *testing.T) {
+	err := setConfigurationWithCustomOptions("false", "false", "false")
+	assert.Nil(t, err, "expected nil error on set configuration with custom options")
+	defer unsetConfiguration(t, "DBSHIFT_DUMMY_DIRECTIVES_STATUS")
+
+	db := &directivesDbImplementation{dummyDbImplementation: dummyDbImplementation{envStatus: "DBSHIFT_DUMMY_DIRECTIVES_STATUS"}}
+	directivesCmd, err := NewCmdWithConfiguration(db, Configuration{
+		Environment:  "prod",
+		Environments: map[string]ConfigurationOptions{"prod": {}},
+	})
+	assert.Nil(t, err, "expected nil error")
+
+	migrationsPath := os.Getenv(envPathMigrations)
+	writeMigrationFile(t, migrationsPath, newMigration("20200101000000", "index", migrationTypeUpgrade, "txt"), "-- dbshift:no-transaction timeout=1m\nCREATE INDEX CONCURRENTLY idx ON t (c);")
+	writeMigrationFile(t, migrationsPath, newMigration("20200101000000", "index", migrationTypeDowngrade, "txt"), "-- dbshift:irreversible\n")
+	writeMigrationFile(t, migrationsPath, newMigration("20200102000000", "seed", migrationTypeUpgrade, "txt"), "-- dbshift:env=dev\nINSERT INTO t VALUES (1);")
+	writeMigrationFile(t, migrationsPath, newMigration("20200102000000", "seed", migrationTypeDowngrade, "txt"), "-- dbshift:env=dev\nDELETE FROM t;")
+
+	// Migrations restricted to other environments are excluded
+	migrationList, err := directivesCmd.getUpgradePlan("")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"20200101000000"}, getVersions(migrationList))
+
+	// Directives are passed to the database
+	assert.Nil(t, directivesCmd.execMigrations(migrationList))
+	assert.Equal(t, []MigrationDirectives{{IsTransactionDisabled: true, Timeout: time.Minute}}, db.directives)
+
+	// Irreversible migrations cannot be downgraded
+	_, err = directivesCmd.getDowngradePlan("", false)
+	assert.NotNil(t, err, "expected error on irreversible migration")
+
+	// Bad directives
+	writeMigrationFiles(t, migrationsPath, "20200103000000", "bad")
+	writeMigrationFile(t, migrationsPath, newMigration("20200103000000", "bad", migrationTypeUpgrade, "txt"), "-- dbshift:no-transactoin\n")
+	_, err = directivesCmd.getUpgradePlan("")
+	assert.NotNil(t, err, "expected error on unknown directive")
+}
+
+// Helpers
+
+type directivesDbImplementation struct {
+	dummyDbImplementation
+	directives []MigrationDirectives
+}
+
+func (db *directivesDbImplementation) SetDirectives(directives MigrationDirectives) error {
+	db.directives = append(db.directives, directives)
+	return nil
+}
+
+func writeMigrationFile(t *testing.T, migrationsPath string, m Migration, content string) {
+	err := ioutil.WriteFile(m.getLocation(migrationsPath), []byte(content), 0664)
+	assert.Nil(t, err)
+}
@@ -14,6 +14,28 @@ type iDatabase interface {
 	ExecuteMigration([]byte) error
 }
 
+// iHistoryDatabase is an optional capability of the database implementation.
+// GetHistory returns the executed migrations (along with their batch) in execution order.
+type iHistoryDatabase interface {
+	GetHistory() ([]Migration, error)
+}
+
+// iRepeatableDatabase is an optional capability of the database implementation, required by repeatable migrations.
+// GetRepeatableChecksums returns the checksum of the last execution of every repeatable migration by name,
+// SetRepeatableStatus records an execution.
+type iRepeatableDatabase interface {
+	GetRepeatableChecksums() (map[string]string, error)
+	SetRepeatableStatus(migration RepeatableMigration, executionTimeInSeconds float64) error
+}
+
+// iStatementDatabase is an optional capability of the database implementation.
+// When implemented, migrations are split by the core according to GetDialect (e.g. mysql, postgres, sqlite)
+// and executed one statement at a time instead of passing the whole file to ExecuteMigration.
+type iStatementDatabase interface {
+	GetDialect() string
+	ExecuteStatement([]byte) error
+}
+
 // Status is a structure used to identify the current (latest) migration version and type executed on database.
 type Status struct {
 	Version string
@@ -21,10 +43,17 @@ type Status struct {
 }
 
 // Migration is a structure used to group the essential information regarding the database-schema migration.
+// Batch identifies the migrations executed together by a single run, it is zero when the database does not provide history.
+// Directives are parsed from the header of the migration file.
+// Dir is the migrations location the file comes from, Folder is the folder of the file relative to it (empty at its root).
 type Migration struct {
-	Version string
-	Name    string
-	Type    migrationType
+	Version    string
+	Name       string
+	Type       migrationType
+	Batch      uint
+	Directives MigrationDirectives
+	Dir        string
+	Folder     string
 }
 
 // Migration type
@@ -80,8 +109,35 @@ func newMigrationFileName(version string, migrationName string, migrationType mi
 	return fmt.Sprintf("%s-%s.%s.%s", version, migrationName, migrationType.String(), extension)
 }
 
+// getCounterpart returns the migration reverting this one (the downgrade of an upgrade and vice versa).
+func (m Migration) getCounterpart() Migration {
+	counterpartType := migrationTypeUpgrade
+	if m.Type == migrationTypeUpgrade {
+		counterpartType = migrationTypeDowngrade
+	}
+
+	suffix := fmt.Sprintf(".%s.", m.Type.String())
+	i := strings.LastIndex(m.Name, suffix)
+	if i == -1 {
+		return Migration{Version: m.Version, Name: m.Name, Type: counterpartType, Batch: m.Batch, Dir: m.Dir, Folder: m.Folder}
+	}
+
+	return Migration{
+		Version: m.Version,
+		Name:    m.Name[:i] + fmt.Sprintf(".%s.", counterpartType.String()) + m.Name[i+len(suffix):],
+		Type:    counterpartType,
+		Batch:   m.Batch,
+		Dir:     m.Dir,
+		Folder:  m.Folder,
+	}
+}
+
+// getLocation returns the path of the migration file, inside the given migrations path unless the migration comes from a known location.
 func (m *Migration) getLocation(migrationsPath string) string {
-	return filepath.Join(migrationsPath, m.Name)
+	if m.Dir != "" {
+		migrationsPath = m.Dir
+	}
+	return filepath.Join(migrationsPath, m.Folder, m.Name)
 }
 
 func newMigrationTypeFromFileIndex(fileIndex uint) migrationType {
@@ -100,10 +156,22 @@ func newMigrationFromFile(fileName string, fileIndex uint) (*Migration, error) {
 	return &Migration{
 		Version: fileName[:*indexDelimiter],
 		Name:    fileName,
-		Type:    newMigrationTypeFromFileIndex(fileIndex),
+		Type:    newMigrationTypeFromFileName(fileName, fileIndex),
 	}, nil
 }
 
+// newMigrationTypeFromFileName returns the type written in the file name, falling back to the file index.
+func newMigrationTypeFromFileName(fileName string, fileIndex uint) migrationType {
+	switch {
+	case strings.Contains(fileName, "."+migrationTypeUpgrade.String()+"."):
+		return migrationTypeUpgrade
+	case strings.Contains(fileName, "."+migrationTypeDowngrade.String()+"."):
+		return migrationTypeDowngrade
+	default:
+		return newMigrationTypeFromFileIndex(fileIndex)
+	}
+}
+
 func getDelimiterIndexFromFileName(fileName string, delimiter rune) (*int, error) {
 	indexDelimiter := strings.IndexRune(fileName, '-')
 	if indexDelimiter == -1 {
@@ -143,6 +143,18 @@ func TestMigrationGetLocation(t *testing.T) {
 	}
 }
 
+func TestMigrationGetCounterpart(t *testing.T) {
+	upgrade := newMigration("20190926154408", "hello-world.up", migrationTypeUpgrade, "sql")
+	upgrade.Batch = 3
+
+	downgrade := upgrade.getCounterpart()
+	assert.Equal(t, "20190926154408-hello-world.up.down.sql", downgrade.Name)
+	assert.Equal(t, migrationTypeDowngrade, downgrade.Type)
+	assert.Equal(t, uint(3), downgrade.Batch)
+
+	assert.Equal(t, upgrade, downgrade.getCounterpart())
+}
+
 func TestMigrationFromFile(t *testing.T) {
 
 	inputs := []string{
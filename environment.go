@@ -0,0 +1,32 @@
+package dbshiftcore
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// environmentsFolder is the folder of the migrations restricted to an environment, e.g. env/dev/20200101000000-seed.up.sql.
+const environmentsFolder = "env"
+
+// getFolderEnvironment returns the environment the folder is restricted to, or an empty string.
+func getFolderEnvironment(folder string) string {
+	parts := strings.Split(filepath.ToSlash(folder), "/")
+	if len(parts) < 2 || parts[0] != environmentsFolder {
+		return ""
+	}
+	return parts[1]
+}
+
+// getSkipReason returns why the migration cannot run in the given environment, or an empty string when it can.
+func (m Migration) getSkipReason(environment string) string {
+	if folderEnvironment := getFolderEnvironment(m.Folder); folderEnvironment != "" && folderEnvironment != environment {
+		return fmt.Sprintf("restricted to environment %s by folder", folderEnvironment)
+	}
+
+	if !m.Directives.isEnvironmentAllowed(environment) {
+		return fmt.Sprintf("restricted to environments %s by directive", strings.Join(m.Directives.Environments, ","))
+	}
+
+	return ""
+}
@@ -0,0 +1,70 @@
+package dbshiftcore
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGetFolderEnvironment(t *testing.T) {
+	assert.Equal(t, "", getFolderEnvironment(""))
+	assert.Equal(t, "", getFolderEnvironment("env"))
+	assert.Equal(t, "", getFolderEnvironment("billing/env/dev"))
+	assert.Equal(t, "dev", getFolderEnvironment("env/dev"))
+	assert.Equal(t, "ci", getFolderEnvironment(filepath.Join("env", "ci", "fixtures")))
+}
+
+func TestMigration_GetSkipReason(t *testing.T) {
+	assert.Equal(t, "", Migration{}.getSkipReason("prod"))
+	assert.Equal(t, "", Migration{Folder: "env/dev"}.getSkipReason("dev"))
+	assert.Equal(t, "restricted to environment dev by folder", Migration{Folder: "env/dev"}.getSkipReason("prod"))
+
+	m := Migration{Directives: MigrationDirectives{Environments: []string{"dev", "ci"}}}
+	assert.Equal(t, "", m.getSkipReason("ci"))
+	assert.Equal(t, "restricted to environments dev,ci by directive", m.getSkipReason("prod"))
+
+	m.Folder = "env/ci"
+	assert.Equal(t, "restricted to environment ci by folder", m.getSkipReason("dev"))
+}
+
+func TestCmd_EnvironmentFolder(t *testing.T) {
+	err := setConfigurationWithCustomOptions("false", "false", "false")
+	assert.Nil(t, err, "expected nil error on set configuration with custom options")
+	defer unsetConfiguration(t, "DBSHIFT_DUMMY_ENVIRONMENT_STATUS")
+
+	migrationsPath := os.Getenv(envPathMigrations)
+	seedPath := filepath.Join(migrationsPath, environmentsFolder, "dev")
+	assert.Nil(t, os.MkdirAll(seedPath, 0775))
+	defer os.RemoveAll(filepath.Join(migrationsPath, environmentsFolder))
+
+	writeMigrationFiles(t, migrationsPath, "20200101000000", "schema")
+	writeMigrationFiles(t, seedPath, "20200102000000", "seed")
+
+	for _, test := range []struct {
+		environment      string
+		expectedVersions []string
+	}{
+		{environment: "prod", expectedVersions: []string{"20200101000000"}},
+		{environment: "dev", expectedVersions: []string{"20200101000000", "20200102000000"}},
+	} {
+		environment := test.environment
+		db := &dummyDbImplementation{envStatus: "DBSHIFT_DUMMY_ENVIRONMENT_STATUS"}
+		environmentCmd, err := NewCmdWithConfiguration(db, Configuration{
+			Environment:  environment,
+			Environments: map[string]ConfigurationOptions{environment: {}},
+		})
+		assert.Nil(t, err, "expected nil error")
+
+		migrationList, err := environmentCmd.getUpgradePlan("")
+		assert.Nil(t, err)
+		assert.Equal(t, test.expectedVersions, getVersions(migrationList), "environment %s", environment)
+		assert.Nil(t, environmentCmd.status())
+
+		// Seed migrations are read from their own folder
+		if environment == "dev" {
+			assert.Equal(t, filepath.Join(seedPath, migrationList[1].Name), migrationList[1].getLocation(migrationsPath))
+			assert.Nil(t, environmentCmd.execMigrations(migrationList))
+		}
+	}
+}
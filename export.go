@@ -0,0 +1,159 @@
+package dbshiftcore
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	flagDown   = "down"
+	flagOutput = "output"
+)
+
+// iExportDatabase is an optional capability of the database implementation, required by export.
+// GetStatusStatements returns the statements recording the execution of the migration,
+// i.e. what SetStatus would do after executing it, so that the status stays consistent when the script is run by hand.
+type iExportDatabase interface {
+	GetStatusStatements(migration Migration) ([]byte, error)
+}
+
+// iRepeatableExportDatabase is an optional capability of the database implementation,
+// required to export the changed repeatable migrations along with the upgrades.
+// GetRepeatableStatusStatements returns the statements recording the execution as SetRepeatableStatus would.
+type iRepeatableExportDatabase interface {
+	GetRepeatableStatusStatements(migration RepeatableMigration) ([]byte, error)
+}
+
+// exportPlan is the content of an exported script.
+type exportPlan struct {
+	migrationList  []Migration
+	repeatableList []RepeatableMigration
+	contents       map[string][]byte
+}
+
+// getExportPlan returns the pending upgrades or, when downgrading, the downgrades till the given version.
+// As upgrade does, an upgrade without target version includes the repeatable migrations changed since their last execution.
+func (c *cmd) getExportPlan(migrationType migrationType, toInclusiveVersion string, isForced bool) (*exportPlan, error) {
+	if migrationType == migrationTypeDowngrade {
+		migrationList, err := c.getDowngradePlan(toInclusiveVersion, isForced)
+		if err != nil {
+			return nil, err
+		}
+		return &exportPlan{migrationList: migrationList}, nil
+	}
+
+	migrationList, err := c.getUpgradePlan(toInclusiveVersion)
+	if err != nil {
+		return nil, err
+	}
+	plan := &exportPlan{migrationList: migrationList}
+	if toInclusiveVersion != "" {
+		return plan, nil
+	}
+
+	repeatableList, contents, err := c.getRepeatableMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	if plan.repeatableList, err = c.filterChangedRepeatableMigrations(repeatableList); err != nil {
+		return nil, err
+	}
+	plan.contents = contents
+
+	return plan, nil
+}
+
+// size returns the amount of migrations of the plan.
+func (p *exportPlan) size() int {
+	return len(p.migrationList) + len(p.repeatableList)
+}
+
+// checkExport returns an error when the database implementation cannot export the plan.
+func (c *cmd) checkExport(plan *exportPlan) error {
+	if _, ok := c.db.(iExportDatabase); !ok {
+		return errors.New("export is not supported by the database implementation")
+	}
+	if _, ok := c.db.(iRepeatableExportDatabase); !ok && len(plan.repeatableList) > 0 {
+		return errors.New("export of repeatable migrations is not supported by the database implementation")
+	}
+	return nil
+}
+
+// export writes the migrations as a single script, each one followed by the statements updating the status.
+// A last statement without delimiter is terminated, so that it does not run into the status statements.
+func (c *cmd) export(w io.Writer, plan *exportPlan) error {
+	if err := c.checkExport(plan); err != nil {
+		return err
+	}
+
+	dialect, err := c.getSQLDialect()
+	if err != nil {
+		return err
+	}
+
+	// Every migration of the script shares the same batch
+	batch, err := c.getNextBatch()
+	if err != nil {
+		return err
+	}
+
+	for _, m := range plan.migrationList {
+		m.Batch = batch
+		location := m.getLocation(c.getMigrationsPath())
+
+		data, err := c.readMigration(m)
+		if err != nil {
+			return &MigrationError{Migration: m, Location: location, Err: err}
+		}
+
+		statusData, err := c.db.(iExportDatabase).GetStatusStatements(m)
+		if err != nil {
+			return &MigrationError{Migration: m, Location: location, Err: fmt.Errorf("status statements not generated: %s", err)}
+		}
+
+		if err := writeExportedMigration(w, m.Name, data, statusData, dialect); err != nil {
+			return &MigrationError{Migration: m, Location: location, Err: err}
+		}
+	}
+
+	for _, r := range plan.repeatableList {
+		m := r.getMigration()
+		location := m.getLocation(c.getMigrationsPath())
+
+		statusData, err := c.db.(iRepeatableExportDatabase).GetRepeatableStatusStatements(r)
+		if err != nil {
+			return &MigrationError{Migration: m, Location: location, Err: fmt.Errorf("status statements not generated: %s", err)}
+		}
+
+		if err := writeExportedMigration(w, r.Name, plan.contents[r.Name], statusData, dialect); err != nil {
+			return &MigrationError{Migration: m, Location: location, Err: err}
+		}
+	}
+
+	return nil
+}
+
+// writeExportedMigration writes the migration followed by its status statements.
+func writeExportedMigration(w io.Writer, name string, data []byte, statusData []byte, dialect sqlDialect) error {
+	body, err := terminateScript(string(data), dialect)
+	if err != nil {
+		return err
+	}
+
+	statusBody, err := terminateScript(string(statusData), dialect)
+	if err != nil {
+		return fmt.Errorf("status statements not parsed: %s", err)
+	}
+
+	_, err = fmt.Fprintf(w, "-- %s\n%s\n-- %s status\n%s\n\n", name, body, name, statusBody)
+	return err
+}
+
+// terminateScript returns the trimmed script ending with the delimiter of its last statement.
+func terminateScript(sql string, dialect sqlDialect) (string, error) {
+	sql, err := terminateStatements(sql, dialect)
+	return strings.TrimSpace(sql), err
+}
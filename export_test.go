@@ -0,0 +1,134 @@
+package dbshiftcore
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type exportDbImplementation struct {
+	dummyDbImplementation
+}
+
+func (db *exportDbImplementation) GetStatusStatements(migration Migration) ([]byte, error) {
+	return []byte(fmt.Sprintf("INSERT INTO dbshift (version, type) VALUES ('%s', '%s');", migration.Version, migration.Type)), nil
+}
+
+type repeatableExportDbImplementation struct {
+	repeatableDbImplementation
+}
+
+func (db *repeatableExportDbImplementation) GetStatusStatements(migration Migration) ([]byte, error) {
+	return []byte(fmt.Sprintf("INSERT INTO dbshift (version, type) VALUES ('%s', '%s');", migration.Version, migration.Type)), nil
+}
+
+func (db *repeatableExportDbImplementation) GetRepeatableStatusStatements(migration RepeatableMigration) ([]byte, error) {
+	return []byte(fmt.Sprintf("INSERT INTO dbshift_repeatable (name, checksum) VALUES ('%s', '%s');", migration.Name, migration.Checksum[:8])), nil
+}
+
+func TestCmd_Export(t *testing.T) {
+	err := setConfigurationWithCustomOptions("false", "false", "false")
+	assert.Nil(t, err, "expected nil error on set configuration with custom options")
+	defer unsetConfiguration(t, "DBSHIFT_DUMMY_EXPORT_STATUS")
+
+	db := &exportDbImplementation{dummyDbImplementation: dummyDbImplementation{envStatus: "DBSHIFT_DUMMY_EXPORT_STATUS"}}
+	exportCmd, err := NewCmd(db)
+	assert.Nil(t, err, "expected nil error")
+
+	migrationsPath := exportCmd.getMigrationsPath()
+	writeMigrationFiles(t, migrationsPath, "20200101000000", "users")
+	writeMigrationFiles(t, migrationsPath, "20200102000000", "orders")
+	users := newMigration("20200101000000", "users", migrationTypeUpgrade, "txt")
+	assert.Nil(t, ioutil.WriteFile(users.getLocation(migrationsPath), []byte("CREATE TABLE users () -- no delimiter\n"), 0664))
+
+	// Pending upgrades
+	plan, err := exportCmd.getExportPlan(migrationTypeUpgrade, "", false)
+	assert.Nil(t, err)
+
+	var b bytes.Buffer
+	assert.Nil(t, exportCmd.export(&b, plan))
+	assert.Equal(t, "-- 20200101000000-users.up.txt\n"+
+		"CREATE TABLE users (); -- no delimiter\n"+
+		"-- 20200101000000-users.up.txt status\n"+
+		"INSERT INTO dbshift (version, type) VALUES ('20200101000000', 'up');\n\n"+
+		"-- 20200102000000-orders.up.txt\n\n"+
+		"-- 20200102000000-orders.up.txt status\n"+
+		"INSERT INTO dbshift (version, type) VALUES ('20200102000000', 'up');\n\n", b.String())
+
+	// Downgrade range
+	assert.Nil(t, exportCmd.upgrade(""))
+	plan, err = exportCmd.getExportPlan(migrationTypeDowngrade, "20200102000000", false)
+	assert.Nil(t, err)
+
+	b.Reset()
+	assert.Nil(t, exportCmd.export(&b, plan))
+	assert.Equal(t, "-- 20200102000000-orders.down.txt\n\n"+
+		"-- 20200102000000-orders.down.txt status\n"+
+		"INSERT INTO dbshift (version, type) VALUES ('20200102000000', 'down');\n\n", b.String())
+
+	// Exporting does not change the status
+	status, err := db.GetStatus()
+	assert.Nil(t, err)
+	assert.Equal(t, Status{Version: "20200102000000", Type: migrationTypeUpgrade}, *status)
+}
+
+func TestCmd_Export_NotSupported(t *testing.T) {
+	err := setConfigurationWithCustomOptions("false", "false", "false")
+	assert.Nil(t, err, "expected nil error on set configuration with custom options")
+	defer unsetConfiguration(t, "DBSHIFT_DUMMY_EXPORT_STATUS")
+
+	exportCmd, err := NewCmd(&dummyDbImplementation{envStatus: "DBSHIFT_DUMMY_EXPORT_STATUS"})
+	assert.Nil(t, err, "expected nil error")
+
+	writeMigrationFiles(t, exportCmd.getMigrationsPath(), "20200101000000", "users")
+	plan, err := exportCmd.getExportPlan(migrationTypeUpgrade, "", false)
+	assert.Nil(t, err)
+	assert.NotNil(t, exportCmd.export(new(bytes.Buffer), plan), "expected error on database without export capability")
+
+	// No script file is left behind
+	output := filepath.Join(exportCmd.getMigrationsPath(), "export.sql")
+	assert.Equal(t, 1, exportCmd.executeCommand([]string{"export", "--output", output}))
+	_, err = os.Stat(output)
+	assert.True(t, os.IsNotExist(err), "expected no script file on database without export capability")
+}
+
+func TestCmd_Export_Repeatable(t *testing.T) {
+	err := setConfigurationWithCustomOptions("false", "false", "false")
+	assert.Nil(t, err, "expected nil error on set configuration with custom options")
+	defer unsetConfiguration(t, "DBSHIFT_DUMMY_EXPORT_STATUS")
+
+	db := &repeatableExportDbImplementation{repeatableDbImplementation: repeatableDbImplementation{
+		dummyDbImplementation: dummyDbImplementation{envStatus: "DBSHIFT_DUMMY_EXPORT_STATUS"},
+		checksums:             map[string]string{},
+	}}
+	exportCmd, err := NewCmd(db)
+	assert.Nil(t, err, "expected nil error")
+
+	migrationsPath := exportCmd.getMigrationsPath()
+	writeMigrationFiles(t, migrationsPath, "20200101000000", "users")
+	writeRepeatableMigrationFile(t, migrationsPath, "R-view.txt", "CREATE OR REPLACE VIEW v AS SELECT 1")
+
+	// Changed repeatable migrations follow the upgrades
+	plan, err := exportCmd.getExportPlan(migrationTypeUpgrade, "", false)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, plan.size())
+
+	var b bytes.Buffer
+	assert.Nil(t, exportCmd.export(&b, plan))
+	assert.Equal(t, "-- 20200101000000-users.up.txt\n\n"+
+		"-- 20200101000000-users.up.txt status\n"+
+		"INSERT INTO dbshift (version, type) VALUES ('20200101000000', 'up');\n\n"+
+		"-- R-view.txt\n"+
+		"CREATE OR REPLACE VIEW v AS SELECT 1;\n"+
+		"-- R-view.txt status\n"+
+		"INSERT INTO dbshift_repeatable (name, checksum) VALUES ('R-view.txt', '"+plan.repeatableList[0].Checksum[:8]+"');\n\n", b.String())
+
+	// Not when exporting till a version, as upgrade does
+	plan, err = exportCmd.getExportPlan(migrationTypeUpgrade, "20200101000000", false)
+	assert.Nil(t, err)
+	assert.Empty(t, plan.repeatableList)
+}
@@ -0,0 +1,174 @@
+package dbshiftcore
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	ignoreFileName = ".dbshiftignore"
+	flagVerbose    = "verbose"
+)
+
+// ignoreRules decides which files of a migrations location are not migrations.
+// Besides hidden files, a file is ignored when its extension differs from the database one
+// or when it matches a glob pattern of the .dbshiftignore file of the location.
+// The folders of the other tracks nested in the location are ignored as well.
+type ignoreRules struct {
+	location   string
+	extension  string
+	patterns   []string
+	trackPaths map[string]string
+}
+
+// ignoredFile is a file of a migrations location ignored by the rules.
+type ignoredFile struct {
+	Location string
+	Reason   string
+}
+
+// loadIgnoreRules reads the .dbshiftignore file of the location, if any.
+// Every line is a glob pattern matched against the file name and against its path relative to the location,
+// empty lines and lines starting with # are skipped.
+func loadIgnoreRules(location string, extension string) (*ignoreRules, error) {
+	rules := &ignoreRules{location: location, extension: strings.TrimPrefix(extension, ".")}
+
+	f, err := os.Open(filepath.Join(location, ignoreFileName))
+	if os.IsNotExist(err) {
+		return rules, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		pattern := strings.TrimSpace(scanner.Text())
+		if pattern == "" || strings.HasPrefix(pattern, "#") {
+			continue
+		}
+
+		pattern = strings.TrimSuffix(pattern, "/")
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("bad pattern %s at line %d of %s: %s", pattern, lineNumber, ignoreFileName, err)
+		}
+		rules.patterns = append(rules.patterns, pattern)
+	}
+
+	return rules, scanner.Err()
+}
+
+// getIgnoreReason returns why the file (or directory) is ignored, or an empty string when it is not.
+func (r *ignoreRules) getIgnoreReason(path string, info os.FileInfo) string {
+	if r == nil || path == r.location {
+		return ""
+	}
+
+	if strings.HasPrefix(info.Name(), ".") {
+		return "hidden"
+	}
+
+	if track, ok := r.trackPaths[path]; ok && info.IsDir() {
+		if track == "" {
+			track = "main"
+		}
+		return fmt.Sprintf("belongs to track %s", track)
+	}
+
+	relativePath, err := filepath.Rel(r.location, path)
+	if err != nil {
+		relativePath = info.Name()
+	}
+	relativePath = filepath.ToSlash(relativePath)
+
+	for _, pattern := range r.patterns {
+		if isMatch(pattern, info.Name()) || isMatch(pattern, relativePath) {
+			return fmt.Sprintf("matches %s in %s", pattern, ignoreFileName)
+		}
+	}
+
+	if !info.IsDir() && r.extension != "" && strings.TrimPrefix(filepath.Ext(info.Name()), ".") != r.extension {
+		return fmt.Sprintf("extension is not %s", r.extension)
+	}
+
+	return ""
+}
+
+func isMatch(pattern string, name string) bool {
+	isMatching, _ := filepath.Match(pattern, name)
+	return isMatching
+}
+
+// getIgnoreRules returns the ignore rules of the location for the database extension and the tracks.
+func (c *cmd) getIgnoreRules(location string) (*ignoreRules, error) {
+	rules, err := loadIgnoreRules(location, c.db.GetExtension())
+	if err != nil {
+		return nil, err
+	}
+
+	rules.trackPaths = c.getOtherTrackPaths(location)
+	return rules, nil
+}
+
+// getIgnoredFiles returns the files ignored in the locations of the active track.
+func (c *cmd) getIgnoredFiles() ([]ignoredFile, error) {
+	var ignoredList []ignoredFile
+
+	for _, location := range c.getMigrationsPaths() {
+		rules, err := c.getIgnoreRules(location)
+		if err != nil {
+			return nil, err
+		}
+
+		err = filepath.Walk(location, func(path string, info os.FileInfo, err error) error {
+			if info == nil {
+				return nil
+			}
+
+			reason := rules.getIgnoreReason(path, info)
+			if reason == "" {
+				return nil
+			}
+
+			// Other tracks are not part of the location
+			if _, ok := rules.trackPaths[path]; ok && info.IsDir() {
+				return filepath.SkipDir
+			}
+			if info.Name() == ignoreFileName || info.Name() == manifestFileName {
+				return nil
+			}
+
+			ignoredList = append(ignoredList, ignoredFile{Location: path, Reason: reason})
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return ignoredList, nil
+}
+
+// printIgnoredFiles prints the files ignored in the locations of the active track, along with the reason.
+func (c *cmd) printIgnoredFiles() error {
+	ignoredList, err := c.getIgnoredFiles()
+	if err != nil {
+		return err
+	}
+
+	if len(ignoredList) > 0 {
+		c.printf("Files ignored\n")
+		for _, f := range ignoredList {
+			c.printf("%s (%s)\n", f.Location, f.Reason)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,67 @@
+package dbshiftcore
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadIgnoreRules(t *testing.T) {
+	location, err := ioutil.TempDir("", "dbshift-ignore")
+	assert.Nil(t, err)
+	defer os.RemoveAll(location)
+
+	rules, err := loadIgnoreRules(location, ".sql")
+	assert.Nil(t, err)
+	assert.Equal(t, "sql", rules.extension)
+	assert.Empty(t, rules.patterns)
+
+	err = ioutil.WriteFile(filepath.Join(location, ignoreFileName), []byte("# Notes\n\nnotes-*\ndrafts/\n"), 0664)
+	assert.Nil(t, err)
+	rules, err = loadIgnoreRules(location, "sql")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"notes-*", "drafts"}, rules.patterns)
+
+	err = ioutil.WriteFile(filepath.Join(location, ignoreFileName), []byte("[\n"), 0664)
+	assert.Nil(t, err)
+	_, err = loadIgnoreRules(location, "sql")
+	assert.NotNil(t, err, "expected error on bad pattern")
+}
+
+func TestCmd_IgnoredFiles(t *testing.T) {
+	err := setConfigurationWithCustomOptions("false", "false", "false")
+	assert.Nil(t, err, "expected nil error on set configuration with custom options")
+	defer unsetConfiguration(t, "DBSHIFT_DUMMY_IGNORE_STATUS")
+
+	ignoreCmd, err := NewCmd(&dummyDbImplementation{envStatus: "DBSHIFT_DUMMY_IGNORE_STATUS"})
+	assert.Nil(t, err, "expected nil error")
+
+	migrationsPath := ignoreCmd.getMigrationsPath()
+	writeMigrationFiles(t, migrationsPath, "20200101000000", "users")
+	assert.Nil(t, os.MkdirAll(filepath.Join(migrationsPath, "drafts"), 0775))
+	writeMigrationFiles(t, filepath.Join(migrationsPath, "drafts"), "20200102000000", "orders")
+	for fileName, content := range map[string]string{
+		"README.md":    "# Migrations",
+		".keep":        "",
+		"notes-v2.txt": "",
+		ignoreFileName: "notes-*\ndrafts/\n",
+	} {
+		assert.Nil(t, ioutil.WriteFile(filepath.Join(migrationsPath, fileName), []byte(content), 0664))
+	}
+
+	migrationList, err := ignoreCmd.getUpgradePlan("")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"20200101000000"}, getVersions(migrationList))
+
+	ignoredList, err := ignoreCmd.getIgnoredFiles()
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, []ignoredFile{
+		{Location: filepath.Join(migrationsPath, ".keep"), Reason: "hidden"},
+		{Location: filepath.Join(migrationsPath, "README.md"), Reason: "extension is not txt"},
+		{Location: filepath.Join(migrationsPath, "drafts"), Reason: "matches drafts in .dbshiftignore"},
+		{Location: filepath.Join(migrationsPath, "notes-v2.txt"), Reason: "matches notes-* in .dbshiftignore"},
+	}, ignoredList)
+	assert.Nil(t, ignoreCmd.printIgnoredFiles())
+}
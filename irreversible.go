@@ -0,0 +1,140 @@
+package dbshiftcore
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sort"
+)
+
+const flagForce = "force"
+
+// IrreversibleError is returned when a downgrade would cross an irreversible migration.
+type IrreversibleError struct {
+	Migration Migration
+	Reason    string
+}
+
+func (e *IrreversibleError) Error() string {
+	return fmt.Sprintf("migration %s is irreversible (%s): use --%s to downgrade anyway", e.Migration.Name, e.Reason, flagForce)
+}
+
+// checkReversibility checks every upgrade crossed by the downgrade.
+// A migration is irreversible when marked by directive or, in strict mode, when its downgrade is missing or empty.
+func (c *cmd) checkReversibility(status Status, toInclusiveVersion string, downgradeList []Migration) error {
+	downgradableFn, err := c.getDowngradableFilter(status)
+	if err != nil {
+		return err
+	}
+
+	upgradeList, err := c.getMigrations(status, toInclusiveVersion, func(m Migration, status Status, toInclusiveVersion string) bool {
+		return m.Type == migrationTypeUpgrade && downgradableFn(m.getCounterpart(), status, toInclusiveVersion)
+	})
+	if err != nil {
+		return err
+	}
+
+	upgradeList = c.filterByEnvironment(upgradeList)
+	sort.Sort(downgradePerspective(upgradeList))
+
+	downgrades := map[string]Migration{}
+	for _, m := range downgradeList {
+		downgrades[m.Version] = m
+	}
+
+	dialect, err := c.getSQLDialect()
+	if err != nil {
+		return err
+	}
+
+	isStrict := c.cfg.getOptions().IsStrict
+	for _, upgrade := range upgradeList {
+		downgrade, ok := downgrades[upgrade.Version]
+
+		switch {
+		case upgrade.Directives.IsIrreversible:
+			return &IrreversibleError{Migration: upgrade, Reason: "marked by directive"}
+		case ok && downgrade.Directives.IsIrreversible:
+			return &IrreversibleError{Migration: downgrade, Reason: "marked by directive"}
+		case !ok && isStrict:
+			return &IrreversibleError{Migration: upgrade, Reason: "missing downgrade"}
+		case ok && isStrict:
+			location := downgrade.getLocation(c.getMigrationsPath())
+			isEmpty, err := isEmptyMigration(location, dialect)
+			if err != nil {
+				return &MigrationError{Migration: downgrade, Location: location, Err: err}
+			}
+			if isEmpty {
+				return &IrreversibleError{Migration: downgrade, Reason: "empty downgrade"}
+			}
+		}
+	}
+
+	return nil
+}
+
+// isEmptyMigration returns true when the migration file has no statement (only spaces and comments).
+// A file that cannot be split is an error, since its reversibility is unknown.
+func isEmptyMigration(location string, dialect sqlDialect) (bool, error) {
+	data, err := ioutil.ReadFile(location)
+	if err != nil {
+		return false, err
+	}
+
+	statements, err := splitStatements(string(data), dialect)
+	if err != nil {
+		return false, err
+	}
+
+	return len(statements) == 0, nil
+}
+
+// goTo upgrades or downgrades the migrations in order to reach the version.
+func (c *cmd) goTo(version string, isForced bool) error {
+	_, migrationList, err := c.getGotoPlan(version, isForced)
+	if err != nil {
+		return err
+	}
+
+	return c.execMigrations(migrationList)
+}
+
+// getGotoPlan returns the migrations to reach the version, along with the direction.
+// Reaching a version means that its upgrade is the last one applied.
+func (c *cmd) getGotoPlan(version string, isForced bool) (migrationType, []Migration, error) {
+	versionList, err := c.getMigrations(Status{}, "", func(m Migration, status Status, toInclusiveVersion string) bool {
+		return m.Type == migrationTypeUpgrade && (m.Version == version || m.Version > version)
+	})
+	if err != nil {
+		return migrationTypeUpgrade, nil, err
+	}
+
+	sort.Sort(upgradePerspective(versionList))
+	if len(versionList) == 0 || versionList[0].Version != version {
+		return migrationTypeUpgrade, nil, fmt.Errorf("migration %s does not exist", version)
+	}
+
+	status, err := c.db.GetStatus()
+	if err != nil {
+		return migrationTypeUpgrade, nil, err
+	}
+
+	upgradableFn, err := c.getUpgradableFilter(*status)
+	if err != nil {
+		return migrationTypeUpgrade, nil, err
+	}
+
+	// Forward
+	if upgradableFn(versionList[0], *status, "") {
+		migrationList, err := c.getUpgradePlan(version)
+		return migrationTypeUpgrade, migrationList, err
+	}
+
+	// Backward: every migration following the version, the status one when its upgrade is missing
+	nextVersion := status.Version
+	if len(versionList) > 1 {
+		nextVersion = versionList[1].Version
+	}
+
+	migrationList, err := c.getDowngradePlan(nextVersion, isForced)
+	return migrationTypeDowngrade, migrationList, err
+}
@@ -0,0 +1,133 @@
+package dbshiftcore
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCmd_Downgrade_Irreversible(t *testing.T) {
+	err := setConfigurationWithCustomOptions("false", "false", "false")
+	assert.Nil(t, err, "expected nil error on set configuration with custom options")
+	defer unsetConfiguration(t, "DBSHIFT_DUMMY_IRREVERSIBLE_STATUS")
+
+	irreversibleCmd, err := NewCmd(&dummyDbImplementation{envStatus: "DBSHIFT_DUMMY_IRREVERSIBLE_STATUS"})
+	assert.Nil(t, err, "expected nil error")
+
+	migrationsPath := os.Getenv(envPathMigrations)
+	writeMigrationFile(t, migrationsPath, newMigration("20200101000000", "first", migrationTypeUpgrade, "txt"), "CREATE TABLE first (id INT);")
+	writeMigrationFile(t, migrationsPath, newMigration("20200101000000", "first", migrationTypeDowngrade, "txt"), "DROP TABLE first;")
+	writeMigrationFile(t, migrationsPath, newMigration("20200102000000", "second", migrationTypeUpgrade, "txt"), "-- dbshift:irreversible\nDROP TABLE legacy;")
+	writeMigrationFile(t, migrationsPath, newMigration("20200102000000", "second", migrationTypeDowngrade, "txt"), "")
+	writeMigrationFile(t, migrationsPath, newMigration("20200103000000", "third", migrationTypeUpgrade, "txt"), "CREATE TABLE third (id INT);")
+	writeMigrationFile(t, migrationsPath, newMigration("20200103000000", "third", migrationTypeDowngrade, "txt"), "-- nothing to do\n")
+	writeMigrationFile(t, migrationsPath, newMigration("20200104000000", "fourth", migrationTypeUpgrade, "txt"), "CREATE TABLE fourth (id INT);")
+	assert.Nil(t, irreversibleCmd.upgrade(""))
+
+	// Missing downgrade is allowed without strict mode
+	migrationList, err := irreversibleCmd.getDowngradePlan("20200103000000", false)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"20200103000000"}, getVersions(migrationList))
+
+	// Directive blocks the downgrade
+	_, err = irreversibleCmd.getDowngradePlan("", false)
+	irreversibleErr, ok := err.(*IrreversibleError)
+	assert.True(t, ok, "expected irreversible error")
+	assert.Equal(t, "20200102000000", irreversibleErr.Migration.Version)
+
+	migrationList, err = irreversibleCmd.getDowngradePlan("", true)
+	assert.Nil(t, err, "expected forced downgrade")
+	assert.Equal(t, []string{"20200103000000", "20200102000000", "20200101000000"}, getVersions(migrationList))
+
+	// Strict mode blocks missing and empty downgrades
+	irreversibleCmd.cfg.Options.IsStrict = true
+	_, err = irreversibleCmd.getDowngradePlan("20200104000000", false)
+	irreversibleErr, ok = err.(*IrreversibleError)
+	assert.True(t, ok, "expected irreversible error")
+	assert.Equal(t, "missing downgrade", irreversibleErr.Reason)
+
+	writeMigrationFile(t, migrationsPath, newMigration("20200104000000", "fourth", migrationTypeDowngrade, "txt"), "DROP TABLE fourth;")
+	_, err = irreversibleCmd.getDowngradePlan("20200103000000", false)
+	irreversibleErr, ok = err.(*IrreversibleError)
+	assert.True(t, ok, "expected irreversible error")
+	assert.Equal(t, "empty downgrade", irreversibleErr.Reason)
+
+	// Goto refuses to cross irreversible migrations too
+	_, _, err = irreversibleCmd.getGotoPlan("20200101000000", false)
+	assert.NotNil(t, err, "expected irreversible error on goto")
+
+	migrationType, migrationList, err := irreversibleCmd.getGotoPlan("20200102000000", true)
+	assert.Nil(t, err)
+	assert.Equal(t, migrationTypeDowngrade, migrationType)
+	assert.Equal(t, []string{"20200104000000", "20200103000000"}, getVersions(migrationList))
+
+	assert.Nil(t, irreversibleCmd.goTo("20200104000000", false))
+	assert.Nil(t, irreversibleCmd.goTo("20200103000000", true))
+
+	migrationType, migrationList, err = irreversibleCmd.getGotoPlan("20200104000000", false)
+	assert.Nil(t, err)
+	assert.Equal(t, migrationTypeUpgrade, migrationType)
+	assert.Equal(t, []string{"20200104000000"}, getVersions(migrationList))
+
+	_, _, err = irreversibleCmd.getGotoPlan("20200105000000", false)
+	assert.NotNil(t, err, "expected error on unexisting version")
+}
+
+func TestCmd_Goto_MissingDowngrade(t *testing.T) {
+	err := setConfigurationWithCustomOptions("false", "false", "false")
+	assert.Nil(t, err, "expected nil error on set configuration with custom options")
+	defer unsetConfiguration(t, "DBSHIFT_DUMMY_GOTO_STATUS")
+
+	gotoCmd, err := NewCmd(&dummyDbImplementation{envStatus: "DBSHIFT_DUMMY_GOTO_STATUS"})
+	assert.Nil(t, err, "expected nil error")
+
+	migrationsPath := os.Getenv(envPathMigrations)
+	writeMigrationFile(t, migrationsPath, newMigration("20200101000000", "first", migrationTypeUpgrade, "txt"), "CREATE TABLE first (id INT);")
+	writeMigrationFile(t, migrationsPath, newMigration("20200102000000", "second", migrationTypeUpgrade, "txt"), "CREATE TABLE second (id INT);")
+
+	// The target version needs no downgrade to be reached
+	migrationType, migrationList, err := gotoCmd.getGotoPlan("20200102000000", false)
+	assert.Nil(t, err)
+	assert.Equal(t, migrationTypeUpgrade, migrationType)
+	assert.Equal(t, []string{"20200101000000", "20200102000000"}, getVersions(migrationList))
+	assert.Nil(t, gotoCmd.goTo("20200102000000", false))
+
+	// Strict mode refuses to go back over a missing downgrade
+	gotoCmd.cfg.Options.IsStrict = true
+	_, _, err = gotoCmd.getGotoPlan("20200101000000", false)
+	irreversibleErr, ok := err.(*IrreversibleError)
+	assert.True(t, ok, "expected irreversible error")
+	assert.Equal(t, "20200102000000", irreversibleErr.Migration.Version)
+	assert.Equal(t, "missing downgrade", irreversibleErr.Reason)
+}
+
+func TestIsEmptyMigration(t *testing.T) {
+	migrationsPath := setExistingMigrationPath(t)
+
+	isEmpty, err := isEmptyMigration(filepath.Join(migrationsPath, "20190926154408-hello-world.down.sql"), sqlDialects[""])
+	assert.Nil(t, err)
+	assert.False(t, isEmpty)
+
+	_, err = isEmptyMigration(filepath.Join(migrationsPath, "unexisting.sql"), sqlDialects[""])
+	assert.NotNil(t, err)
+}
+
+func TestIsEmptyMigration_Unparsable(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dbshift-irreversible")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	location := filepath.Join(dir, "1-broken.down.sql")
+	assert.Nil(t, ioutil.WriteFile(location, []byte("DELETE FROM users WHERE name = 'unterminated;"), 0664))
+
+	_, err = isEmptyMigration(location, sqlDialects[""])
+	assert.NotNil(t, err, "expected error on a downgrade that cannot be split")
+}
+
+func TestNewMigrationTypeFromFileName(t *testing.T) {
+	assert.Equal(t, migrationTypeUpgrade, newMigrationTypeFromFileName("123-hello.up.sql", 0))
+	assert.Equal(t, migrationTypeDowngrade, newMigrationTypeFromFileName("123-hello.down.sql", 1))
+	assert.Equal(t, migrationTypeUpgrade, newMigrationTypeFromFileName("123-hello.sql", 1))
+}
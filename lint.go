@@ -0,0 +1,263 @@
+package dbshiftcore
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	flagFormat   = "format"
+	formatText   = "text"
+	formatJSON   = "json"
+	exitCodeLint = 2
+)
+
+// LintRule is a static check of the content of a migration.
+// Check returns the findings of the migration, the core fills their rule, migration and location.
+type LintRule struct {
+	Name        string
+	Description string
+	Check       func(m Migration, statements []Statement) []LintFinding
+}
+
+// LintFinding is a problem found by a lint rule in a migration.
+type LintFinding struct {
+	Rule      string `json:"rule"`
+	Migration string `json:"migration"`
+	Location  string `json:"location"`
+	Line      int    `json:"line"`
+	Message   string `json:"message"`
+}
+
+// iLintDatabase is an optional capability of the database implementation.
+// GetLintRules returns rules checked along with the core ones.
+type iLintDatabase interface {
+	GetLintRules() []LintRule
+}
+
+var (
+	lintDropTableRegexp        = regexp.MustCompile(`(?is)^DROP\s+TABLE\b`)
+	lintDropTableGuardRegexp   = regexp.MustCompile(`(?is)^DROP\s+TABLE\s+IF\s+EXISTS\b`)
+	lintAddNotNullRegexp       = regexp.MustCompile(`(?is)^ALTER\s+TABLE\b.*\bADD\b.*\bNOT\s+NULL\b`)
+	lintDefaultRegexp          = regexp.MustCompile(`(?is)\bDEFAULT\b`)
+	lintDatabaseNameRegexp     = regexp.MustCompile(`(?is)^(USE\s+\S+|(CREATE|ALTER|DROP)\s+DATABASE\b)`)
+	lintQualifiedNameRegexp    = regexp.MustCompile("(?is)\\b(?:FROM|INTO|JOIN|TABLE|UPDATE)\\s+(?:IF\\s+(?:NOT\\s+)?EXISTS\\s+)?((?:\\w+|`[^`]+`|\"[^\"]+\")\\.(?:\\w+|`[^`]+`|\"[^\"]+\"))")
+	lintStatementKeywordRegexp = regexp.MustCompile(`^[A-Za-z]+`)
+)
+
+var ddlKeywords = []string{"CREATE", "ALTER", "DROP", "TRUNCATE", "RENAME", "COMMENT"}
+var dmlKeywords = []string{"INSERT", "UPDATE", "DELETE", "MERGE", "REPLACE"}
+
+var lintRules = []LintRule{{
+	Name:        "empty",
+	Description: "the migration has no statement",
+	Check: func(m Migration, statements []Statement) []LintFinding {
+		if len(statements) > 0 || m.Directives.IsIrreversible {
+			return nil
+		}
+		return []LintFinding{{Line: 1, Message: "empty migration: write it or mark it irreversible"}}
+	},
+}, {
+	Name:        "drop-table-without-guard",
+	Description: "DROP TABLE without IF EXISTS",
+	Check: checkStatements(func(s Statement) string {
+		if lintDropTableRegexp.MatchString(s.Text) && !lintDropTableGuardRegexp.MatchString(s.Text) {
+			return "DROP TABLE without IF EXISTS"
+		}
+		return ""
+	}),
+}, {
+	Name:        "not-null-without-default",
+	Description: "ALTER TABLE adding a NOT NULL column without a default",
+	Check: checkStatements(func(s Statement) string {
+		if lintAddNotNullRegexp.MatchString(s.Text) && !lintDefaultRegexp.MatchString(s.Text) {
+			return "NOT NULL column added without a default"
+		}
+		return ""
+	}),
+}, {
+	Name:        "database-name",
+	Description: "statement bound to a database name, migrations must be database name agnostic",
+	Check: checkStatements(func(s Statement) string {
+		if match := lintDatabaseNameRegexp.FindString(s.Text); match != "" {
+			return fmt.Sprintf("database name hardcoded by %s", strings.Join(strings.Fields(match), " "))
+		}
+		return ""
+	}),
+}, {
+	Name:        "mixed-ddl-dml",
+	Description: "schema changes (DDL) mixed with data changes (DML)",
+	Check: func(m Migration, statements []Statement) []LintFinding {
+		var ddl, dml *Statement
+		for i := range statements {
+			keyword := strings.ToUpper(lintStatementKeywordRegexp.FindString(statements[i].Text))
+			switch {
+			case ddl == nil && containsString(ddlKeywords, keyword):
+				ddl = &statements[i]
+			case dml == nil && containsString(dmlKeywords, keyword):
+				dml = &statements[i]
+			}
+		}
+		if ddl == nil || dml == nil {
+			return nil
+		}
+		return []LintFinding{{Line: dml.StartLine, Message: fmt.Sprintf("data change mixed with the schema change at line %d", ddl.StartLine)}}
+	},
+}}
+
+// checkQualifiedNames reports the tables qualified by a database name, e.g. shop.users.
+var checkQualifiedNames = checkStatements(func(s Statement) string {
+	if match := lintQualifiedNameRegexp.FindStringSubmatch(s.Text); match != nil {
+		return fmt.Sprintf("database name hardcoded by the qualified name %s", match[1])
+	}
+	return ""
+})
+
+// checkStatements returns a check reporting every statement for which the function returns a message.
+func checkStatements(fn func(s Statement) string) func(m Migration, statements []Statement) []LintFinding {
+	return func(m Migration, statements []Statement) []LintFinding {
+		var findings []LintFinding
+		for _, s := range statements {
+			if message := fn(s); message != "" {
+				findings = append(findings, LintFinding{Line: s.StartLine, Message: message})
+			}
+		}
+		return findings
+	}
+}
+
+// getLintRules returns the core rules followed by the database ones.
+// Qualified names are checked for MySQL only, where the qualifier is a database while it is a schema elsewhere.
+func (c *cmd) getLintRules() []LintRule {
+	rules := append([]LintRule{}, lintRules...)
+	if statementDb, ok := c.db.(iStatementDatabase); ok && strings.EqualFold(statementDb.GetDialect(), "mysql") {
+		rules = append(rules, c.getQualifiedNameLintRule())
+	}
+	if lintDb, ok := c.db.(iLintDatabase); ok {
+		rules = append(rules, lintDb.GetLintRules()...)
+	}
+	return rules
+}
+
+// getQualifiedNameLintRule returns the database-name rule checking qualified names.
+// With templating, the unrendered migration is checked so that qualifiers coming from variables are not reported.
+func (c *cmd) getQualifiedNameLintRule() LintRule {
+	return LintRule{
+		Name:        "database-name",
+		Description: "table qualified by a database name, migrations must be database name agnostic",
+		Check: func(m Migration, statements []Statement) []LintFinding {
+			if c.cfg.getOptions().IsTemplatingEnabled {
+				if unrenderedStatements, err := c.getUnrenderedStatements(m); err == nil {
+					statements = unrenderedStatements
+				}
+			}
+			return checkQualifiedNames(m, statements)
+		},
+	}
+}
+
+// getUnrenderedStatements splits the migration file as written, before templating.
+func (c *cmd) getUnrenderedStatements(m Migration) ([]Statement, error) {
+	data, err := ioutil.ReadFile(m.getLocation(c.getMigrationsPath()))
+	if err != nil {
+		return nil, err
+	}
+
+	dialect, err := c.getSQLDialect()
+	if err != nil {
+		return nil, err
+	}
+
+	return splitStatements(string(data), dialect)
+}
+
+// lint checks the pending migrations, both upgrades and their downgrades, and returns the findings.
+// Rules listed by the lint-ignore directive of a migration are not checked on it.
+func (c *cmd) lint() ([]LintFinding, error) {
+	status, err := c.db.GetStatus()
+	if err != nil {
+		return nil, err
+	}
+
+	upgradableFn, err := c.getUpgradableFilter(*status)
+	if err != nil {
+		return nil, err
+	}
+
+	migrationList, err := c.getMigrations(*status, "", func(m Migration, status Status, toInclusiveVersion string) bool {
+		if m.Type == migrationTypeUpgrade {
+			return upgradableFn(m, status, toInclusiveVersion)
+		}
+		return upgradableFn(m.getCounterpart(), status, toInclusiveVersion)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(migrationList, func(i, j int) bool {
+		return migrationList[i].Name < migrationList[j].Name
+	})
+
+	dialect, err := c.getSQLDialect()
+	if err != nil {
+		return nil, err
+	}
+
+	var findings []LintFinding
+	for _, m := range c.filterByEnvironment(migrationList) {
+		location := m.getLocation(c.getMigrationsPath())
+
+		data, err := c.readMigration(m)
+		if err != nil {
+			return nil, err
+		}
+
+		statements, err := splitStatements(string(data), dialect)
+		if err != nil {
+			findings = append(findings, LintFinding{Rule: "syntax", Migration: m.Name, Location: location, Message: err.Error()})
+			continue
+		}
+
+		for _, rule := range c.getLintRules() {
+			if containsString(m.Directives.LintIgnoredRules, rule.Name) {
+				continue
+			}
+			for _, finding := range rule.Check(m, statements) {
+				finding.Rule, finding.Migration, finding.Location = rule.Name, m.Name, location
+				findings = append(findings, finding)
+			}
+		}
+	}
+
+	return findings, nil
+}
+
+// printLintFindings prints the findings in the given format.
+func (c *cmd) printLintFindings(findings []LintFinding, format string) error {
+	switch format {
+	case "", formatText:
+		for _, f := range findings {
+			c.printf("%s:%d: [%s] %s\n", f.Location, f.Line, f.Rule, f.Message)
+		}
+		if len(findings) == 0 {
+			c.printSuccess("No lint findings")
+		}
+		return nil
+	case formatJSON:
+		if findings == nil {
+			findings = []LintFinding{}
+		}
+		data, err := json.MarshalIndent(findings, "", "  ")
+		if err != nil {
+			return err
+		}
+		c.printf("%s\n", string(data))
+		return nil
+	default:
+		return fmt.Errorf("unknown format %s: expected %s or %s", format, formatText, formatJSON)
+	}
+}
@@ -0,0 +1,150 @@
+package dbshiftcore
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestLintRules(t *testing.T) {
+	inputs := map[string][]string{
+		"":                            {"empty"},
+		"DROP TABLE users;":           {"drop-table-without-guard"},
+		"DROP TABLE IF EXISTS users;": nil,
+		"ALTER TABLE users ADD COLUMN a INT NOT NULL;":           {"not-null-without-default"},
+		"ALTER TABLE users ADD COLUMN a INT NOT NULL DEFAULT 0;": nil,
+		"USE app;\nSELECT 1;":                                    {"database-name"},
+		"CREATE DATABASE app;":                                   {"database-name"},
+		"CREATE SCHEMA reporting;":                               nil,
+		"INSERT INTO app.users VALUES (1);":                      nil,
+		"CREATE TABLE t (a INT);\nINSERT INTO t VALUES (1);":     {"mixed-ddl-dml"},
+		"INSERT INTO t VALUES (1);\nUPDATE t SET a = 2;":         nil,
+	}
+
+	for sql, expected := range inputs {
+		statements, err := splitStatements(sql, sqlDialects[""])
+		assert.Nil(t, err)
+
+		var rules []string
+		for _, rule := range lintRules {
+			for range rule.Check(Migration{}, statements) {
+				rules = append(rules, rule.Name)
+			}
+		}
+		assert.Equal(t, expected, rules, "unexpected findings for %q", sql)
+	}
+}
+
+func TestCheckQualifiedNames(t *testing.T) {
+	inputs := map[string]int{
+		"INSERT INTO shop.users VALUES (1);":                     1,
+		"ALTER TABLE `shop`.`orders` ADD COLUMN a INT;":          1,
+		"DROP TABLE IF EXISTS shop.orders;":                      1,
+		"SELECT u.id FROM users u JOIN orders o ON o.id = u.id;": 0,
+		"CREATE TABLE {{.schema}}.greetings (id INT);":           0,
+	}
+
+	for sql, expected := range inputs {
+		statements, err := splitStatements(sql, sqlDialects["mysql"])
+		assert.Nil(t, err)
+		assert.Len(t, checkQualifiedNames(Migration{}, statements), expected, "unexpected findings for %q", sql)
+	}
+}
+
+func TestCmd_Lint_QualifiedNames(t *testing.T) {
+	err := setConfigurationWithCustomOptions("false", "false", "false")
+	assert.Nil(t, err, "expected nil error on set configuration with custom options")
+	defer unsetConfiguration(t, "DBSHIFT_DUMMY_LINT_STATUS")
+
+	db := &statementDbImplementation{dummyDbImplementation: dummyDbImplementation{envStatus: "DBSHIFT_DUMMY_LINT_STATUS"}, dialect: "postgres"}
+	lintCmd, err := NewCmdWithConfiguration(db, Configuration{
+		Options:   ConfigurationOptions{IsTemplatingEnabled: true},
+		Variables: map[string]string{"schema": "app"},
+	})
+	assert.Nil(t, err, "expected nil error")
+
+	migrationsPath := lintCmd.getMigrationsPath()
+	writeMigrationFile(t, migrationsPath, newMigration("20200101000000", "greetings", migrationTypeUpgrade, "txt"), "CREATE TABLE {{.schema}}.greetings (id INT);\nINSERT INTO shop.greetings VALUES (1);")
+	writeMigrationFile(t, migrationsPath, newMigration("20200101000000", "greetings", migrationTypeDowngrade, "txt"), "DROP TABLE IF EXISTS {{.schema}}.greetings;")
+
+	// Qualifiers are schemas outside MySQL
+	findings, err := lintCmd.lint()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"mixed-ddl-dml"}, getLintRuleNames(findings))
+
+	// Qualifiers coming from variables are not reported
+	db.dialect = "mysql"
+	findings, err = lintCmd.lint()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"mixed-ddl-dml", "database-name"}, getLintRuleNames(findings))
+	assert.Equal(t, "database name hardcoded by the qualified name shop.greetings", findings[1].Message)
+	assert.Equal(t, 2, findings[1].Line)
+}
+
+func TestCmd_Lint(t *testing.T) {
+	err := setConfigurationWithCustomOptions("false", "false", "false")
+	assert.Nil(t, err, "expected nil error on set configuration with custom options")
+	defer unsetConfiguration(t, "DBSHIFT_DUMMY_LINT_STATUS")
+
+	db := &lintDbImplementation{dummyDbImplementation: dummyDbImplementation{envStatus: "DBSHIFT_DUMMY_LINT_STATUS"}}
+	lintCmd, err := NewCmd(db)
+	assert.Nil(t, err, "expected nil error")
+
+	migrationsPath := lintCmd.getMigrationsPath()
+	writeMigrationFile(t, migrationsPath, newMigration("20200101000000", "users", migrationTypeUpgrade, "txt"), "CREATE TABLE users (id INT);\n\nDROP TABLE accounts;")
+	writeMigrationFile(t, migrationsPath, newMigration("20200101000000", "users", migrationTypeDowngrade, "txt"), "-- dbshift:lint-ignore=drop-table-without-guard\nDROP TABLE users;")
+	writeMigrationFile(t, migrationsPath, newMigration("20200102000000", "seed", migrationTypeUpgrade, "txt"), "-- dbshift:lint-ignore=mixed-ddl-dml,no-truncate\nTRUNCATE t;\nINSERT INTO t VALUES (1);")
+	writeMigrationFile(t, migrationsPath, newMigration("20200102000000", "seed", migrationTypeDowngrade, "txt"), "-- dbshift:irreversible\n")
+
+	users := newMigration("20200101000000", "users", migrationTypeUpgrade, "txt")
+	findings, err := lintCmd.lint()
+	assert.Nil(t, err)
+	assert.Equal(t, []LintFinding{{
+		Rule:      "drop-table-without-guard",
+		Migration: "20200101000000-users.up.txt",
+		Location:  users.getLocation(migrationsPath),
+		Line:      3,
+		Message:   "DROP TABLE without IF EXISTS",
+	}, {
+		Rule:      "no-truncate",
+		Migration: "20200101000000-users.up.txt",
+		Location:  users.getLocation(migrationsPath),
+		Line:      0,
+		Message:   "checked",
+	}}, findings)
+
+	assert.Nil(t, lintCmd.printLintFindings(findings, formatJSON))
+	assert.Nil(t, lintCmd.printLintFindings(nil, formatText))
+	assert.NotNil(t, lintCmd.printLintFindings(findings, "xml"), "expected error on unknown format")
+
+	// Applied migrations are not checked
+	assert.Nil(t, lintCmd.upgrade(""))
+	findings, err = lintCmd.lint()
+	assert.Nil(t, err)
+	assert.Empty(t, findings)
+}
+
+// Helpers
+
+func getLintRuleNames(findings []LintFinding) []string {
+	var names []string
+	for _, f := range findings {
+		names = append(names, f.Rule)
+	}
+	return names
+}
+
+type lintDbImplementation struct {
+	dummyDbImplementation
+}
+
+func (db *lintDbImplementation) GetLintRules() []LintRule {
+	return []LintRule{{
+		Name: "no-truncate",
+		Check: func(m Migration, statements []Statement) []LintFinding {
+			if m.Type != migrationTypeUpgrade {
+				return nil
+			}
+			return []LintFinding{{Message: "checked"}}
+		},
+	}}
+}
@@ -0,0 +1,109 @@
+package dbshiftcore
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// splitMigrationsPaths splits a list of migrations locations joined by the OS path list separator (e.g. "/vendor/platform:/srv/app").
+func splitMigrationsPaths(paths string) []string {
+	var list []string
+	for _, path := range filepath.SplitList(paths) {
+		if path != "" {
+			list = append(list, filepath.Clean(path))
+		}
+	}
+	return list
+}
+
+// getMigrationsPaths returns the ordered migrations locations of the active track.
+func (c *cmd) getMigrationsPaths() []string {
+	return c.cfg.getTrackPaths(c.cfg.Track)
+}
+
+// getMigrationsPath returns the first migrations location of the active track, where new migrations are created.
+func (c *cmd) getMigrationsPath() string {
+	if paths := c.getMigrationsPaths(); len(paths) > 0 {
+		return paths[0]
+	}
+	return ""
+}
+
+// getMigrations merges the migrations of every location of the active track, excluding the folders of the other tracks.
+// A version defined in more than one location is an error.
+func (c *cmd) getMigrations(status Status, toInclusiveVersion string, filterFn migrationFilterFn) ([]Migration, error) {
+	if err := c.checkVersionCollisions(); err != nil {
+		return nil, err
+	}
+
+	var migrationList []Migration
+	for _, migrationsPath := range c.getMigrationsPaths() {
+		rules, err := c.getIgnoreRules(migrationsPath)
+		if err != nil {
+			return nil, err
+		}
+
+		locationList, err := getMigrations(migrationsPath, rules, status, toInclusiveVersion, filterFn)
+		if err != nil {
+			return nil, err
+		}
+
+		for i := range locationList {
+			locationList[i].Dir = migrationsPath
+		}
+		migrationList = append(migrationList, locationList...)
+	}
+
+	return migrationList, nil
+}
+
+// checkVersionCollisions returns an error when a version is defined in more than one location of the active track.
+func (c *cmd) checkVersionCollisions() error {
+	migrationsPaths := c.getMigrationsPaths()
+	if len(migrationsPaths) < 2 {
+		return nil
+	}
+
+	dirs := map[string]string{}
+	collisions := map[string]string{}
+	for _, migrationsPath := range migrationsPaths {
+		location := migrationsPath
+		rules, err := c.getIgnoreRules(location)
+		if err != nil {
+			return err
+		}
+
+		_, err = getMigrations(location, rules, Status{}, "", func(m Migration, status Status, toInclusiveVersion string) bool {
+			if dir, ok := dirs[m.Version]; ok && dir != location {
+				collisions[m.Version] = fmt.Sprintf("%s (%s, %s)", m.Version, dir, location)
+				return false
+			}
+			dirs[m.Version] = location
+			return false
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(collisions) > 0 {
+		var list []string
+		for _, collision := range collisions {
+			list = append(list, collision)
+		}
+		sort.Strings(list)
+		return fmt.Errorf("migration versions defined in more than one location: %s", strings.Join(list, ", "))
+	}
+
+	return nil
+}
+
+// getMigrationLabel returns the migration name, along with its location when the active track has more than one.
+func (c *cmd) getMigrationLabel(m Migration) string {
+	if m.Dir == "" || len(c.getMigrationsPaths()) < 2 {
+		return m.Name
+	}
+	return fmt.Sprintf("%s [%s]", m.Name, m.Dir)
+}
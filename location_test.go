@@ -0,0 +1,50 @@
+package dbshiftcore
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSplitMigrationsPaths(t *testing.T) {
+	separator := string(filepath.ListSeparator)
+	assert.Equal(t, []string{"/vendor/platform", "/srv/app"}, splitMigrationsPaths("/vendor/platform/"+separator+separator+"/srv/app"))
+	assert.Equal(t, []string{"migrations"}, splitMigrationsPaths("migrations"))
+	assert.Empty(t, splitMigrationsPaths(""))
+}
+
+func TestCmd_MultipleLocations(t *testing.T) {
+	err := setConfigurationWithCustomOptions("false", "false", "false")
+	assert.Nil(t, err, "expected nil error on set configuration with custom options")
+	defer unsetConfiguration(t, "DBSHIFT_DUMMY_LOCATION_STATUS")
+
+	appPath := os.Getenv(envPathMigrations)
+	platformPath := filepath.Join(appPath, "..", "platform")
+	assert.Nil(t, os.MkdirAll(platformPath, 0775))
+	defer os.RemoveAll(platformPath)
+
+	writeMigrationFiles(t, platformPath, "20200101000000", "tenants")
+	writeMigrationFiles(t, appPath, "20200102000000", "users")
+	writeMigrationFiles(t, platformPath, "20200103000000", "audit")
+
+	assert.Nil(t, os.Setenv(envPathMigrations, platformPath+string(filepath.ListSeparator)+appPath))
+	locationCmd, err := NewCmd(&dummyDbImplementation{envStatus: "DBSHIFT_DUMMY_LOCATION_STATUS"})
+	assert.Nil(t, err, "expected nil error")
+	assert.Equal(t, filepath.Clean(platformPath), locationCmd.getMigrationsPath())
+
+	// Locations are merged into one plan, remembering the origin of every migration
+	migrationList, err := locationCmd.getUpgradePlan("")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"20200101000000", "20200102000000", "20200103000000"}, getVersions(migrationList))
+	assert.Equal(t, filepath.Clean(appPath), migrationList[1].Dir)
+	assert.Equal(t, filepath.Join(appPath, migrationList[1].Name), migrationList[1].getLocation(locationCmd.getMigrationsPath()))
+	assert.Equal(t, migrationList[1].Dir, migrationList[1].getCounterpart().Dir)
+	assert.Nil(t, locationCmd.status())
+	assert.Nil(t, locationCmd.execMigrations(migrationList))
+
+	// A version defined in more than one location is an error
+	writeMigrationFiles(t, appPath, "20200103000000", "audit")
+	_, err = locationCmd.getDowngradePlan("", false)
+	assert.NotNil(t, err, "expected error on version collision")
+}
@@ -0,0 +1,223 @@
+package dbshiftcore
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	manifestFileName = "dbshift.sum"
+	exitCodeValidate = 3
+)
+
+// manifestEntry is the line of a migration in the manifest: version, path relative to the location and content hash.
+// Repeatable migrations have no version.
+type manifestEntry struct {
+	Version string
+	Path    string
+	Hash    string
+}
+
+// manifest is the integrity manifest of a migrations location by migration path.
+type manifest map[string]manifestEntry
+
+// ManifestError is returned when the migrations of a location do not match its manifest.
+type ManifestError struct {
+	Location   string
+	Mismatches []string
+}
+
+func (e *ManifestError) Error() string {
+	return fmt.Sprintf("migrations do not match %s: %s", filepath.Join(e.Location, manifestFileName), strings.Join(e.Mismatches, ", "))
+}
+
+// readManifest reads the manifest of the location, returning false when the location has none.
+func readManifest(location string) (manifest, bool, error) {
+	f, err := os.Open(filepath.Join(location, manifestFileName))
+	if os.IsNotExist(err) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	defer f.Close()
+
+	m := manifest{}
+	scanner := bufio.NewScanner(f)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		switch len(fields) {
+		case 2:
+			m[fields[0]] = manifestEntry{Path: fields[0], Hash: fields[1]}
+		case 3:
+			m[fields[1]] = manifestEntry{Version: fields[0], Path: fields[1], Hash: fields[2]}
+		default:
+			return nil, true, fmt.Errorf("bad line %d of %s", lineNumber, manifestFileName)
+		}
+	}
+
+	return m, true, scanner.Err()
+}
+
+// write writes the manifest in the location, sorted by version and path, so repeatable migrations come first.
+func (m manifest) write(location string) error {
+	entries := make([]manifestEntry, 0, len(m))
+	for _, entry := range m {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Version != entries[j].Version {
+			return entries[i].Version < entries[j].Version
+		}
+		return entries[i].Path < entries[j].Path
+	})
+
+	var b strings.Builder
+	for _, entry := range entries {
+		if entry.Version == "" {
+			fmt.Fprintf(&b, "%s %s\n", entry.Path, entry.Hash)
+			continue
+		}
+		fmt.Fprintf(&b, "%s %s %s\n", entry.Version, entry.Path, entry.Hash)
+	}
+
+	return ioutil.WriteFile(filepath.Join(location, manifestFileName), []byte(b.String()), 0664)
+}
+
+// getManifestEntry returns the manifest entry of the migration file.
+func getManifestEntry(m Migration, location string) (manifestEntry, error) {
+	data, err := ioutil.ReadFile(m.getLocation(location))
+	if err != nil {
+		return manifestEntry{}, err
+	}
+
+	hash := sha256.Sum256(data)
+	return manifestEntry{
+		Version: m.Version,
+		Path:    filepath.ToSlash(filepath.Join(m.Folder, m.Name)),
+		Hash:    "sha256:" + hex.EncodeToString(hash[:]),
+	}, nil
+}
+
+// computeManifest returns the manifest of the migrations currently in the location, repeatable ones included.
+func (c *cmd) computeManifest(location string) (manifest, error) {
+	rules, err := c.getIgnoreRules(location)
+	if err != nil {
+		return nil, err
+	}
+
+	migrationList, err := getMigrations(location, rules, Status{}, "", func(m Migration, status Status, toInclusiveVersion string) bool {
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	repeatableList, err := getRepeatableMigrationFiles(location, rules)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range repeatableList {
+		migrationList = append(migrationList, r.getMigration())
+	}
+
+	computed := manifest{}
+	for _, m := range migrationList {
+		entry, err := getManifestEntry(m, location)
+		if err != nil {
+			return nil, err
+		}
+		computed[entry.Path] = entry
+	}
+
+	return computed, nil
+}
+
+// hash writes the manifest of every location of the active track.
+func (c *cmd) hash() error {
+	for _, location := range c.getMigrationsPaths() {
+		computed, err := c.computeManifest(location)
+		if err != nil {
+			return err
+		}
+		if err := computed.write(location); err != nil {
+			return err
+		}
+		c.printSuccess("Manifest %s has been written with %d migrations", filepath.Join(location, manifestFileName), len(computed))
+	}
+	return nil
+}
+
+// validate checks the migrations of every location of the active track against its manifest.
+// Locations without manifest are not checked.
+func (c *cmd) validate() error {
+	for _, location := range c.getMigrationsPaths() {
+		expected, ok, err := readManifest(location)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+
+		computed, err := c.computeManifest(location)
+		if err != nil {
+			return err
+		}
+
+		var mismatches []string
+		for path, entry := range computed {
+			expectedEntry, ok := expected[path]
+			switch {
+			case !ok:
+				mismatches = append(mismatches, fmt.Sprintf("%s is not listed", path))
+			case expectedEntry.Hash != entry.Hash:
+				mismatches = append(mismatches, fmt.Sprintf("%s has been modified", path))
+			}
+		}
+		for path := range expected {
+			if _, ok := computed[path]; !ok {
+				mismatches = append(mismatches, fmt.Sprintf("%s is missing", path))
+			}
+		}
+
+		if len(mismatches) > 0 {
+			sort.Strings(mismatches)
+			return &ManifestError{Location: location, Mismatches: mismatches}
+		}
+	}
+
+	return nil
+}
+
+// updateManifest removes and adds the migrations in the manifest of the location, when the location has one.
+func updateManifest(location string, removedList []Migration, addedList []Migration) error {
+	existing, ok, err := readManifest(location)
+	if err != nil || !ok {
+		return err
+	}
+
+	for _, m := range removedList {
+		delete(existing, filepath.ToSlash(filepath.Join(m.Folder, m.Name)))
+	}
+
+	for _, m := range addedList {
+		entry, err := getManifestEntry(m, location)
+		if err != nil {
+			return err
+		}
+		existing[entry.Path] = entry
+	}
+
+	return existing.write(location)
+}
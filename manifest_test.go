@@ -0,0 +1,97 @@
+package dbshiftcore
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCmd_Manifest(t *testing.T) {
+	err := setConfigurationWithCustomOptions("false", "false", "false")
+	assert.Nil(t, err, "expected nil error on set configuration with custom options")
+	defer unsetConfiguration(t, "DBSHIFT_DUMMY_MANIFEST_STATUS")
+
+	manifestCmd, err := NewCmd(&dummyDbImplementation{envStatus: "DBSHIFT_DUMMY_MANIFEST_STATUS"})
+	assert.Nil(t, err, "expected nil error")
+
+	migrationsPath := manifestCmd.getMigrationsPath()
+	writeMigrationFiles(t, migrationsPath, "20200101000000", "users")
+	writeMigrationFiles(t, migrationsPath, "20200102000000", "orders")
+
+	// Locations without manifest are not checked
+	assert.Nil(t, manifestCmd.validate())
+
+	assert.Nil(t, manifestCmd.hash())
+	data, err := ioutil.ReadFile(filepath.Join(migrationsPath, manifestFileName))
+	assert.Nil(t, err)
+	assert.Contains(t, string(data), "20200101000000 20200101000000-users.down.txt sha256:")
+	assert.Nil(t, manifestCmd.validate())
+
+	// Created migrations are added to the manifest
+	assert.Nil(t, manifestCmd.create("invoices", ""))
+	assert.Nil(t, manifestCmd.validate())
+
+	// Modified files
+	users := newMigration("20200101000000", "users", migrationTypeUpgrade, "txt")
+	assert.Nil(t, ioutil.WriteFile(users.getLocation(migrationsPath), []byte("CREATE TABLE users ();"), 0664))
+	err = manifestCmd.validate()
+	assert.IsType(t, &ManifestError{}, err)
+	assert.Equal(t, []string{"20200101000000-users.up.txt has been modified"}, err.(*ManifestError).Mismatches)
+
+	_, err = manifestCmd.getUpgradePlan("")
+	assert.IsType(t, &ManifestError{}, err, "expected upgrade to refuse a modified folder")
+	assert.IsType(t, &ManifestError{}, manifestCmd.status(), "expected status to refuse a modified folder")
+
+	// Missing and unlisted files
+	assert.Nil(t, manifestCmd.hash())
+	orders := newMigration("20200102000000", "orders", migrationTypeDowngrade, "txt")
+	assert.Nil(t, os.Rename(orders.getLocation(migrationsPath), filepath.Join(migrationsPath, "20200102000000-orders-v2.down.txt")))
+	err = manifestCmd.validate()
+	assert.IsType(t, &ManifestError{}, err)
+	assert.Equal(t, []string{
+		"20200102000000-orders-v2.down.txt is not listed",
+		"20200102000000-orders.down.txt is missing",
+	}, err.(*ManifestError).Mismatches)
+
+	// Bad manifest
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(migrationsPath, manifestFileName), []byte("20200101000000 sha256:abc\n"), 0664))
+	assert.NotNil(t, manifestCmd.validate(), "expected error on bad manifest")
+}
+
+func TestCmd_Manifest_Repeatable(t *testing.T) {
+	err := setConfigurationWithCustomOptions("false", "false", "false")
+	assert.Nil(t, err, "expected nil error on set configuration with custom options")
+	defer unsetConfiguration(t, "DBSHIFT_DUMMY_MANIFEST_STATUS")
+
+	manifestCmd, err := NewCmd(&dummyDbImplementation{envStatus: "DBSHIFT_DUMMY_MANIFEST_STATUS"})
+	assert.Nil(t, err, "expected nil error")
+
+	migrationsPath := manifestCmd.getMigrationsPath()
+	writeMigrationFiles(t, migrationsPath, "20200101000000", "users")
+	view := filepath.Join(migrationsPath, "R-users-view.txt")
+	assert.Nil(t, ioutil.WriteFile(view, []byte("CREATE OR REPLACE VIEW active_users AS SELECT 1;"), 0664))
+
+	// Repeatable migrations are listed without version
+	assert.Nil(t, manifestCmd.hash())
+	data, err := ioutil.ReadFile(filepath.Join(migrationsPath, manifestFileName))
+	assert.Nil(t, err)
+	assert.Regexp(t, "^R-users-view.txt sha256:", string(data))
+	assert.Nil(t, manifestCmd.validate())
+
+	// Created migrations keep the repeatable ones listed
+	assert.Nil(t, manifestCmd.create("orders", ""))
+	assert.Nil(t, manifestCmd.validate())
+
+	// Modified and removed repeatable migrations
+	assert.Nil(t, ioutil.WriteFile(view, []byte("CREATE OR REPLACE VIEW active_users AS SELECT 2;"), 0664))
+	err = manifestCmd.validate()
+	assert.IsType(t, &ManifestError{}, err)
+	assert.Equal(t, []string{"R-users-view.txt has been modified"}, err.(*ManifestError).Mismatches)
+
+	assert.Nil(t, os.Remove(view))
+	err = manifestCmd.validate()
+	assert.IsType(t, &ManifestError{}, err)
+	assert.Equal(t, []string{"R-users-view.txt is missing"}, err.(*ManifestError).Mismatches)
+}
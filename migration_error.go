@@ -0,0 +1,40 @@
+package dbshiftcore
+
+import (
+	"fmt"
+	"strings"
+)
+
+const statementExcerptLength = 60
+
+// MigrationError is returned when a migration fails.
+// Statement and StatementIndex (1-based) are set when the database executes one statement at a time.
+type MigrationError struct {
+	Migration      Migration
+	Location       string
+	Statement      *Statement
+	StatementIndex int
+	Err            error
+}
+
+func (e *MigrationError) Error() string {
+	if e.Statement == nil {
+		return fmt.Sprintf("migration %s failed: %s", e.Migration.Name, e.Err)
+	}
+	return fmt.Sprintf("migration %s failed at statement %d (lines %d-%d) %q: %s",
+		e.Migration.Name, e.StatementIndex, e.Statement.StartLine, e.Statement.EndLine, e.Statement.getExcerpt(), e.Err)
+}
+
+// Unwrap returns the error of the database implementation.
+func (e *MigrationError) Unwrap() error {
+	return e.Err
+}
+
+// getExcerpt returns the beginning of the statement on a single line.
+func (s Statement) getExcerpt() string {
+	excerpt := strings.Join(strings.Fields(s.Text), " ")
+	if len(excerpt) > statementExcerptLength {
+		return excerpt[:statementExcerptLength] + "..."
+	}
+	return excerpt
+}
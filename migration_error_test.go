@@ -0,0 +1,56 @@
+package dbshiftcore
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestMigrationError_Error(t *testing.T) {
+	m := newMigration("20190926154408", "hello-world", migrationTypeUpgrade, "sql")
+	err := &MigrationError{Migration: m, Err: errFailingMigration}
+	assert.Equal(t, "migration 20190926154408-hello-world.up.sql failed: failing migration", err.Error())
+
+	err.Statement = &Statement{Text: "INSERT INTO greetings\n  VALUES ('hello-world')", StartLine: 3, EndLine: 4}
+	err.StatementIndex = 2
+	assert.Equal(t, `migration 20190926154408-hello-world.up.sql failed at statement 2 (lines 3-4) "INSERT INTO greetings VALUES ('hello-world')": failing migration`, err.Error())
+	assert.Equal(t, errFailingMigration, errors.Unwrap(err))
+}
+
+func TestStatement_GetExcerpt(t *testing.T) {
+	s := Statement{Text: strings.Repeat("a", statementExcerptLength+1)}
+	assert.Equal(t, strings.Repeat("a", statementExcerptLength)+"...", s.getExcerpt())
+}
+
+func TestCmd_ExecMigration_Error(t *testing.T) {
+	err := setConfigurationWithCustomOptions("false", "false", "false")
+	assert.Nil(t, err, "expected nil error on set configuration with custom options")
+	defer unsetConfiguration(t, "DBSHIFT_DUMMY_ERROR_STATUS")
+
+	db := &statementDbImplementation{dummyDbImplementation: dummyDbImplementation{envStatus: "DBSHIFT_DUMMY_ERROR_STATUS"}}
+	errorCmd, err := NewCmd(db)
+	assert.Nil(t, err, "expected nil error")
+
+	migrationsPath := os.Getenv(envPathMigrations)
+	m := newMigration("20200101000000", "failing", migrationTypeUpgrade, "txt")
+	err = ioutil.WriteFile(m.getLocation(migrationsPath), []byte("SELECT 1;\n\nSELECT\n  'FAIL';"), 0664)
+	assert.Nil(t, err)
+
+	err = errorCmd.execMigration(m)
+	migrationErr, ok := err.(*MigrationError)
+	assert.True(t, ok, "expected migration error")
+	assert.Equal(t, m.Name, migrationErr.Migration.Name)
+	assert.Equal(t, m.getLocation(migrationsPath), migrationErr.Location)
+	assert.Equal(t, 2, migrationErr.StatementIndex)
+	assert.Equal(t, 3, migrationErr.Statement.StartLine)
+	assert.Equal(t, 4, migrationErr.Statement.EndLine)
+
+	// Missing file
+	err = errorCmd.execMigration(newMigration("20200101000000", "missing", migrationTypeUpgrade, "txt"))
+	migrationErr, ok = err.(*MigrationError)
+	assert.True(t, ok, "expected migration error")
+	assert.Nil(t, migrationErr.Statement)
+}
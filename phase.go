@@ -0,0 +1,136 @@
+package dbshiftcore
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	flagPhase = "phase"
+
+	// phasePre is the expand phase, executed before deploying the application.
+	// Migrations without a phase belong to it.
+	phasePre = "pre"
+	// phasePost is the contract phase, executed after deploying the application.
+	phasePost = "post"
+)
+
+func checkPhase(phase string) error {
+	if phase != phasePre && phase != phasePost {
+		return fmt.Errorf("unknown phase %s: expected %s or %s", phase, phasePre, phasePost)
+	}
+	return nil
+}
+
+// getPhase returns the deployment phase of the migration.
+func (m Migration) getPhase() string {
+	if m.Directives.Phase == "" {
+		return phasePre
+	}
+	return m.Directives.Phase
+}
+
+// PhaseError is returned when upgrading a phase would leave migrations of that phase pending
+// behind a migration of the other phase.
+type PhaseError struct {
+	Phase       string
+	Blocking    Migration
+	PendingList []Migration
+}
+
+func (e *PhaseError) Error() string {
+	names := make([]string, 0, len(e.PendingList))
+	for _, m := range e.PendingList {
+		names = append(names, m.Name)
+	}
+	return fmt.Sprintf("migrations %s of phase %s are pending behind migration %s of phase %s: upgrade without --%s or reorder them",
+		strings.Join(names, ", "), e.Phase, e.Blocking.Name, e.Blocking.getPhase(), flagPhase)
+}
+
+// getUpgradePhasePlan returns the migrations to upgrade in the given phase, or in any phase when it is empty.
+func (c *cmd) getUpgradePhasePlan(toInclusiveVersion string, phase string) ([]Migration, error) {
+	if phase != "" {
+		if err := checkPhase(phase); err != nil {
+			return nil, err
+		}
+	}
+
+	migrationList, err := c.getUpgradePlan(toInclusiveVersion)
+	if err != nil || phase == "" {
+		return migrationList, err
+	}
+
+	return getPhasePlan(migrationList, phase)
+}
+
+// getPhasePlan returns the upgrades of the plan belonging to the phase.
+// The status is a single version, so a phase can only run the migrations preceding the first one of the other phase:
+// when migrations of the phase follow it, the phase cannot be applied entirely and an error is returned.
+func getPhasePlan(migrationList []Migration, phase string) ([]Migration, error) {
+	for i, m := range migrationList {
+		if m.getPhase() == phase {
+			continue
+		}
+
+		var pendingList []Migration
+		for _, next := range migrationList[i+1:] {
+			if next.getPhase() == phase {
+				pendingList = append(pendingList, next)
+			}
+		}
+		if len(pendingList) > 0 {
+			return nil, &PhaseError{Phase: phase, Blocking: m, PendingList: pendingList}
+		}
+
+		return migrationList[:i], nil
+	}
+	return migrationList, nil
+}
+
+// getPhaseWarnings reports the pending contract migrations that would run before pending expand migrations
+// with a greater version, which they may depend on.
+func getPhaseWarnings(migrationList []Migration) []string {
+	var warnings []string
+	for i, m := range migrationList {
+		if m.getPhase() != phasePost {
+			continue
+		}
+
+		var expandList []string
+		for _, next := range migrationList[i+1:] {
+			if next.getPhase() == phasePre {
+				expandList = append(expandList, next.Name)
+			}
+		}
+
+		if len(expandList) > 0 {
+			warnings = append(warnings, fmt.Sprintf("contract migration %s would run before expand migrations %s", m.Name, strings.Join(expandList, ", ")))
+		}
+	}
+	return warnings
+}
+
+// printPhases prints the pending upgrades of every phase, along with the ordering warnings.
+// Nothing is printed when no pending migration declares a phase.
+func (c *cmd) printPhases(migrationList []Migration) {
+	var hasPhases bool
+	for _, m := range migrationList {
+		hasPhases = hasPhases || m.Directives.Phase != ""
+	}
+	if !hasPhases {
+		return
+	}
+
+	for _, phase := range []string{phasePre, phasePost} {
+		c.printf("Migrations to upgrade in phase %s\n", phase)
+		for _, m := range migrationList {
+			if m.getPhase() == phase {
+				c.printf("%s\n", m.Name)
+			}
+		}
+	}
+
+	for _, warning := range getPhaseWarnings(migrationList) {
+		c.printFailure(warning)
+	}
+}
@@ -0,0 +1,81 @@
+package dbshiftcore
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"testing"
+)
+
+func TestGetPhasePlan(t *testing.T) {
+	migrationList := []Migration{
+		{Version: "1"},
+		{Version: "2", Directives: MigrationDirectives{Phase: phasePre}},
+		{Version: "3", Directives: MigrationDirectives{Phase: phasePost}},
+		{Version: "4"},
+	}
+
+	migrationPlan, err := getPhasePlan(migrationList[:3], phasePre)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"1", "2"}, getVersions(migrationPlan))
+
+	_, err = getPhasePlan(migrationList[:3], phasePost)
+	assert.IsType(t, &PhaseError{}, err, "expected error on contract migration behind expand migrations")
+
+	migrationPlan, err = getPhasePlan(migrationList[2:3], phasePost)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"3"}, getVersions(migrationPlan))
+}
+
+func TestGetPhasePlan_PendingBehindOtherPhase(t *testing.T) {
+	migrationList := []Migration{
+		{Version: "1", Name: "1-expand.up.sql"},
+		{Version: "2", Name: "2-contract.up.sql", Directives: MigrationDirectives{Phase: phasePost}},
+		{Version: "3", Name: "3-expand.up.sql"},
+	}
+
+	_, err := getPhasePlan(migrationList, phasePre)
+	assert.IsType(t, &PhaseError{}, err)
+	assert.Equal(t, "migrations 3-expand.up.sql of phase pre are pending behind migration 2-contract.up.sql of phase post: upgrade without --phase or reorder them", err.Error())
+}
+
+func TestGetPhaseWarnings(t *testing.T) {
+	expand := Migration{Name: "1-expand.up.sql"}
+	contract := Migration{Name: "2-contract.up.sql", Directives: MigrationDirectives{Phase: phasePost}}
+	lateExpand := Migration{Name: "3-expand.up.sql"}
+
+	assert.Empty(t, getPhaseWarnings([]Migration{expand, contract}))
+	assert.Equal(t, []string{"contract migration 2-contract.up.sql would run before expand migrations 3-expand.up.sql"}, getPhaseWarnings([]Migration{expand, contract, lateExpand}))
+}
+
+func TestCmd_UpgradePhase(t *testing.T) {
+	err := setConfigurationWithCustomOptions("false", "false", "false")
+	assert.Nil(t, err, "expected nil error on set configuration with custom options")
+	defer unsetConfiguration(t, "DBSHIFT_DUMMY_PHASE_STATUS")
+
+	phaseCmd, err := NewCmd(&dummyDbImplementation{envStatus: "DBSHIFT_DUMMY_PHASE_STATUS"})
+	assert.Nil(t, err, "expected nil error")
+
+	migrationsPath := os.Getenv(envPathMigrations)
+	writeMigrationFiles(t, migrationsPath, "20200101000000", "add-column")
+	writeMigrationFile(t, migrationsPath, newMigration("20200102000000", "drop-column", migrationTypeUpgrade, "txt"), "-- dbshift:phase=post\n")
+	writeMigrationFile(t, migrationsPath, newMigration("20200102000000", "drop-column", migrationTypeDowngrade, "txt"), "")
+
+	_, err = phaseCmd.getUpgradePhasePlan("", "later")
+	assert.NotNil(t, err, "expected error on unknown phase")
+
+	// Contract migrations wait for the pre phase
+	_, err = phaseCmd.getUpgradePhasePlan("", phasePost)
+	assert.IsType(t, &PhaseError{}, err, "expected error on contract migration pending behind expand migrations")
+	assert.Equal(t, 1, phaseCmd.executeCommand([]string{"upgrade", "--phase", phasePost, "--dry-run"}))
+
+	migrationList, err := phaseCmd.getUpgradePhasePlan("", phasePre)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"20200101000000"}, getVersions(migrationList))
+	assert.Nil(t, phaseCmd.status())
+	assert.Nil(t, phaseCmd.execMigrations(migrationList))
+
+	migrationList, err = phaseCmd.getUpgradePhasePlan("", phasePost)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"20200102000000"}, getVersions(migrationList))
+	assert.Equal(t, phasePost, migrationList[0].Directives.Phase)
+}
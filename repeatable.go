@@ -0,0 +1,193 @@
+package dbshiftcore
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const repeatablePrefix = "R-"
+
+// RepeatableMigration is a migration without version (e.g. a view or a procedure),
+// executed after the upgrades whenever its checksum differs from the last execution.
+type RepeatableMigration struct {
+	Name       string
+	Checksum   string
+	Directives MigrationDirectives
+	Dir        string
+	Folder     string
+}
+
+// getMigration returns the repeatable migration as an upgrade without version.
+func (r RepeatableMigration) getMigration() Migration {
+	return Migration{Name: r.Name, Type: migrationTypeUpgrade, Directives: r.Directives, Dir: r.Dir, Folder: r.Folder}
+}
+
+func isRepeatableMigrationFile(fileName string) bool {
+	return strings.HasPrefix(fileName, repeatablePrefix)
+}
+
+// getRepeatableMigrations returns the repeatable migrations of every location sorted by name, along with their content.
+func (c *cmd) getRepeatableMigrations() ([]RepeatableMigration, map[string][]byte, error) {
+	var repeatableList []RepeatableMigration
+	contents := map[string][]byte{}
+	dirs := map[string]string{}
+
+	for _, migrationsPath := range c.getMigrationsPaths() {
+		rules, err := c.getIgnoreRules(migrationsPath)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		locationList, err := getRepeatableMigrationFiles(migrationsPath, rules)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		for _, r := range locationList {
+			// The name identifies the repeatable migration
+			if dir, ok := dirs[r.Name]; ok && dir != migrationsPath {
+				return nil, nil, fmt.Errorf("repeatable migration %s is defined in both %s and %s", r.Name, dir, migrationsPath)
+			}
+			dirs[r.Name] = migrationsPath
+
+			location := filepath.Join(migrationsPath, r.Folder, r.Name)
+			if r.Directives, err = readDirectives(location); err != nil {
+				return nil, nil, err
+			}
+
+			data, err := ioutil.ReadFile(location)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			if c.cfg.getOptions().IsTemplatingEnabled {
+				if data, err = renderMigration(r.Name, data, c.cfg.Variables); err != nil {
+					return nil, nil, err
+				}
+			}
+
+			// The checksum covers the content as executed
+			checksum := sha256.Sum256(data)
+			r.Checksum = hex.EncodeToString(checksum[:])
+
+			if r.getMigration().getSkipReason(c.cfg.Environment) == "" {
+				repeatableList = append(repeatableList, r)
+				contents[r.Name] = data
+			}
+		}
+	}
+
+	sort.Slice(repeatableList, func(i, j int) bool {
+		return repeatableList[i].Name < repeatableList[j].Name
+	})
+
+	return repeatableList, contents, nil
+}
+
+// getRepeatableMigrationFiles walks the migrations path and returns its repeatable migration files.
+// Files and folders ignored by the rules, when given, are skipped.
+func getRepeatableMigrationFiles(migrationsPath string, rules *ignoreRules) ([]RepeatableMigration, error) {
+	var repeatableList []RepeatableMigration
+
+	err := filepath.Walk(migrationsPath, func(path string, info os.FileInfo, err error) error {
+		if info == nil {
+			return nil
+		}
+		if rules.getIgnoreReason(path, info) != "" {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.IsDir() || !isRepeatableMigrationFile(info.Name()) {
+			return nil
+		}
+
+		r := RepeatableMigration{Name: info.Name(), Dir: migrationsPath}
+		if r.Folder, err = getMigrationFolder(migrationsPath, path); err != nil {
+			return err
+		}
+
+		repeatableList = append(repeatableList, r)
+		return nil
+	})
+
+	return repeatableList, err
+}
+
+// getRepeatablePlan returns the repeatable migrations changed since their last execution.
+func (c *cmd) getRepeatablePlan() ([]RepeatableMigration, error) {
+	repeatableList, _, err := c.getRepeatableMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	return c.filterChangedRepeatableMigrations(repeatableList)
+}
+
+func (c *cmd) filterChangedRepeatableMigrations(repeatableList []RepeatableMigration) ([]RepeatableMigration, error) {
+	if len(repeatableList) == 0 {
+		return nil, nil
+	}
+
+	repeatableDb, ok := c.db.(iRepeatableDatabase)
+	if !ok {
+		return nil, errors.New("repeatable migrations are not supported by the database implementation")
+	}
+
+	checksums, err := repeatableDb.GetRepeatableChecksums()
+	if err != nil {
+		return nil, err
+	}
+
+	var changedList []RepeatableMigration
+	for _, r := range repeatableList {
+		if checksums[r.Name] != r.Checksum {
+			changedList = append(changedList, r)
+		}
+	}
+
+	return changedList, nil
+}
+
+// execRepeatableMigrations executes the repeatable migrations changed since their last execution.
+// When a phase is given, only the repeatable migrations of that phase are executed.
+func (c *cmd) execRepeatableMigrations(phase string) error {
+	repeatableList, contents, err := c.getRepeatableMigrations()
+	if err != nil {
+		return err
+	}
+
+	changedList, err := c.filterChangedRepeatableMigrations(repeatableList)
+	if err != nil {
+		return err
+	}
+
+	for _, r := range changedList {
+		m := r.getMigration()
+		if phase != "" && m.getPhase() != phase {
+			continue
+		}
+		location := m.getLocation(c.getMigrationsPath())
+
+		execTimeInSeconds, err := c.runMigration(m, location, contents[r.Name])
+		if err != nil {
+			return err
+		}
+
+		if err := c.db.(iRepeatableDatabase).SetRepeatableStatus(r, execTimeInSeconds); err != nil {
+			return &MigrationError{Migration: m, Location: location, Err: err}
+		}
+
+		c.printSuccess("Repeatable migration %s has been executed in %v seconds", r.Name, execTimeInSeconds)
+	}
+
+	return nil
+}
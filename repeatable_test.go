@@ -0,0 +1,87 @@
+package dbshiftcore
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"testing"
+)
+
+func TestCmd_RepeatableMigrations(t *testing.T) {
+	err := setConfigurationWithCustomOptions("false", "false", "false")
+	assert.Nil(t, err, "expected nil error on set configuration with custom options")
+	defer unsetConfiguration(t, "DBSHIFT_DUMMY_REPEATABLE_STATUS")
+
+	db := &repeatableDbImplementation{
+		dummyDbImplementation: dummyDbImplementation{envStatus: "DBSHIFT_DUMMY_REPEATABLE_STATUS"},
+		checksums:             map[string]string{},
+	}
+	repeatableCmd, err := NewCmd(db)
+	assert.Nil(t, err, "expected nil error")
+
+	migrationsPath := os.Getenv(envPathMigrations)
+	writeMigrationFiles(t, migrationsPath, "20200101000000", "table")
+	writeRepeatableMigrationFile(t, migrationsPath, "R-view.txt", "CREATE OR REPLACE VIEW v AS SELECT 1;")
+	writeRepeatableMigrationFile(t, migrationsPath, "R-function.txt", "CREATE OR REPLACE FUNCTION f() ...;")
+
+	// Repeatable migrations are not versioned migrations
+	migrationList, err := repeatableCmd.getUpgradePlan("")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"20200101000000"}, getVersions(migrationList))
+
+	repeatableList, err := repeatableCmd.getRepeatablePlan()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"R-function.txt", "R-view.txt"}, getRepeatableNames(repeatableList))
+
+	// Executed after the upgrade
+	assert.Nil(t, repeatableCmd.upgrade(""))
+	assert.Equal(t, []string{"R-function.txt", "R-view.txt"}, db.executions)
+	assert.Equal(t, 1, len(db.history))
+
+	// Executed again only when changed
+	writeRepeatableMigrationFile(t, migrationsPath, "R-view.txt", "CREATE OR REPLACE VIEW v AS SELECT 2;")
+	assert.Nil(t, repeatableCmd.upgrade(""))
+	assert.Equal(t, []string{"R-function.txt", "R-view.txt", "R-view.txt"}, db.executions)
+
+	assert.Nil(t, repeatableCmd.upgrade(""))
+	assert.Equal(t, 3, len(db.executions))
+
+	// Not executed by a partial upgrade
+	writeRepeatableMigrationFile(t, migrationsPath, "R-view.txt", "CREATE OR REPLACE VIEW v AS SELECT 3;")
+	assert.Nil(t, repeatableCmd.upgrade("20200101000000"))
+	assert.Equal(t, 3, len(db.executions))
+
+	// Required capability
+	repeatableCmd.db = noHistoryDbImplementation{db}
+	_, err = repeatableCmd.getRepeatablePlan()
+	assert.NotNil(t, err, "expected error without repeatable capability")
+}
+
+// Helpers
+
+type repeatableDbImplementation struct {
+	dummyDbImplementation
+	checksums  map[string]string
+	executions []string
+}
+
+func (db *repeatableDbImplementation) GetRepeatableChecksums() (map[string]string, error) {
+	return db.checksums, nil
+}
+
+func (db *repeatableDbImplementation) SetRepeatableStatus(migration RepeatableMigration, executionTimeInSeconds float64) error {
+	db.checksums[migration.Name] = migration.Checksum
+	db.executions = append(db.executions, migration.Name)
+	return nil
+}
+
+func writeRepeatableMigrationFile(t *testing.T, migrationsPath string, name string, content string) {
+	writeMigrationFile(t, migrationsPath, Migration{Name: name}, content)
+}
+
+func getRepeatableNames(repeatableList []RepeatableMigration) []string {
+	var names []string
+	for _, r := range repeatableList {
+		names = append(names, r.Name)
+	}
+	return names
+}
@@ -1,11 +1,14 @@
 package dbshiftcore
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
 )
 
-func getMigrations(migrationsPath string, status Status, toInclusiveVersion string, filterFn migrationFilterFn) ([]Migration, error) {
+// getMigrations walks the migrations path and returns the migrations accepted by the filter.
+// Files and folders ignored by the rules, when given, are skipped.
+func getMigrations(migrationsPath string, rules *ignoreRules, status Status, toInclusiveVersion string, filterFn migrationFilterFn) ([]Migration, error) {
 	var migrationList []Migration
 	var fileIndex uint
 
@@ -17,8 +20,15 @@ func getMigrations(migrationsPath string, status Status, toInclusiveVersion stri
 
 		fileName := info.Name()
 
-		// Exclude directories and hidden files
-		if info.IsDir() || fileName[0] == '.' {
+		if rules.getIgnoreReason(path, info) != "" {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		// Exclude directories, hidden files and repeatable migrations
+		if info.IsDir() || fileName[0] == '.' || isRepeatableMigrationFile(fileName) {
 			return nil
 		}
 
@@ -27,7 +37,14 @@ func getMigrations(migrationsPath string, status Status, toInclusiveVersion stri
 			return err
 		}
 
+		if migrationObj.Folder, err = getMigrationFolder(migrationsPath, path); err != nil {
+			return err
+		}
+
 		if filterFn(*migrationObj, status, toInclusiveVersion) {
+			if migrationObj.Directives, err = readDirectives(path); err != nil {
+				return fmt.Errorf("migration %s: %s", fileName, err)
+			}
 			migrationList = append(migrationList, *migrationObj)
 		}
 
@@ -38,3 +55,12 @@ func getMigrations(migrationsPath string, status Status, toInclusiveVersion stri
 
 	return migrationList, err
 }
+
+// getMigrationFolder returns the folder of the file relative to the migrations path.
+func getMigrationFolder(migrationsPath string, location string) (string, error) {
+	folder, err := filepath.Rel(migrationsPath, filepath.Dir(location))
+	if err != nil || folder == "." {
+		return "", err
+	}
+	return folder, nil
+}
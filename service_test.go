@@ -13,14 +13,14 @@ func TestGetMigrations(t *testing.T) {
 		Type:    migrationTypeDowngrade,
 	}
 
-	migrationUpgradeList, err := getMigrations(migrationsPath, status, "", isUpgradable)
+	migrationUpgradeList, err := getMigrations(migrationsPath, nil, status, "", isUpgradable)
 	if err != nil {
 		t.Error(err)
 	} else if len(migrationUpgradeList) != 2 {
 		t.Errorf("unexpected counter of upgrading migrations: %d", len(migrationUpgradeList))
 	}
 
-	migrationDowngradeList, err := getMigrations(migrationsPath, status, "", isDowngradable)
+	migrationDowngradeList, err := getMigrations(migrationsPath, nil, status, "", isDowngradable)
 	if err != nil {
 		t.Error(err)
 	} else if len(migrationDowngradeList) != 0 {
@@ -0,0 +1,248 @@
+package dbshiftcore
+
+import (
+	"fmt"
+	"strings"
+)
+
+const defaultDelimiter = ";"
+
+// Statement is a single SQL statement of a migration file, along with its lines range (1-based, inclusive).
+type Statement struct {
+	Text      string
+	StartLine int
+	EndLine   int
+}
+
+// sqlDialect describes the lexical rules that affect where a statement ends.
+type sqlDialect struct {
+	hashComments     bool
+	backticks        bool
+	backslashEscapes bool
+	escapeStrings    bool
+	dollarQuoting    bool
+	delimiterCommand bool
+}
+
+var sqlDialects = map[string]sqlDialect{
+	"":         {},
+	"mysql":    {hashComments: true, backticks: true, backslashEscapes: true, delimiterCommand: true},
+	"postgres": {escapeStrings: true, dollarQuoting: true},
+	"sqlite":   {backticks: true},
+}
+
+func getSQLDialect(name string) (sqlDialect, error) {
+	dialect, ok := sqlDialects[strings.ToLower(name)]
+	if !ok {
+		return sqlDialect{}, fmt.Errorf("unknown sql dialect %s", name)
+	}
+	return dialect, nil
+}
+
+// getSQLDialect returns the dialect of the database implementation, the standard one when it does not split statements.
+func (c *cmd) getSQLDialect() (sqlDialect, error) {
+	if statementDb, ok := c.db.(iStatementDatabase); ok {
+		return getSQLDialect(statementDb.GetDialect())
+	}
+	return sqlDialects[""], nil
+}
+
+// splitStatements splits a migration into statements, ignoring delimiters inside quotes and comments.
+// Comments preceding a statement and statements made of comments only are dropped.
+func splitStatements(sql string, dialect sqlDialect) ([]Statement, error) {
+	statements, _, _, err := scanStatements(sql, dialect)
+	return statements, err
+}
+
+// terminateStatements returns the migration with the delimiter in effect inserted after its last statement
+// when that one is not terminated, e.g. before a trailing comment.
+func terminateStatements(sql string, dialect sqlDialect) (string, error) {
+	_, unterminatedEnd, delimiter, err := scanStatements(sql, dialect)
+	if err != nil || unterminatedEnd == -1 {
+		return sql, err
+	}
+	return sql[:unterminatedEnd] + delimiter + sql[unterminatedEnd:], nil
+}
+
+// scanStatements returns the statements of the migration, the end offset of the last statement when it is not terminated
+// or -1, and the delimiter in effect at the end.
+func scanStatements(sql string, dialect sqlDialect) ([]Statement, int, string, error) {
+	var statements []Statement
+	var current strings.Builder
+
+	delimiter := defaultDelimiter
+	line := 1
+	startLine, startOffset, hasContent := 0, 0, false
+	contentEnd := 0
+
+	markContent := func() {
+		if !hasContent {
+			hasContent = true
+			startLine = line
+			startOffset = current.Len()
+		}
+	}
+
+	flush := func() {
+		if hasContent {
+			text := strings.TrimRightFunc(current.String()[startOffset:], isSpace)
+			statements = append(statements, Statement{
+				Text:      text,
+				StartLine: startLine,
+				EndLine:   startLine + strings.Count(text, "\n"),
+			})
+		}
+		current.Reset()
+		hasContent = false
+	}
+
+	for i := 0; i < len(sql); {
+		rest := sql[i:]
+
+		// A DELIMITER command can only start a statement
+		if dialect.delimiterCommand && !hasContent && isDelimiterCommand(rest) {
+			end := indexOrLen(rest, "\n")
+			fields := strings.Fields(rest[:end])
+			if len(fields) != 2 {
+				return nil, 0, "", fmt.Errorf("bad delimiter command at line %d", line)
+			}
+			delimiter = fields[1]
+			current.Reset()
+			i += end
+			continue
+		}
+
+		if strings.HasPrefix(rest, delimiter) {
+			flush()
+			i += len(delimiter)
+			continue
+		}
+
+		n := 1
+		switch ch := rest[0]; {
+		case strings.HasPrefix(rest, "--") || (dialect.hashComments && ch == '#'):
+			n = indexOrLen(rest, "\n")
+		case strings.HasPrefix(rest, "/*"):
+			end := strings.Index(rest[2:], "*/")
+			if end == -1 {
+				return nil, 0, "", fmt.Errorf("unterminated comment at line %d", line)
+			}
+			n = end + 4
+		case ch == '\'' || ch == '"' || (dialect.backticks && ch == '`'):
+			markContent()
+			end := indexClosingQuote(rest, ch, dialect.backslashEscapes || (dialect.escapeStrings && isEscapeStringPrefix(sql[:i])))
+			if end == -1 {
+				return nil, 0, "", fmt.Errorf("unterminated quote %c at line %d", ch, line)
+			}
+			n = end + 1
+			contentEnd = i + n
+		case dialect.dollarQuoting && ch == '$':
+			markContent()
+			if tag := getDollarQuoteTag(rest); tag != "" {
+				end := strings.Index(rest[len(tag):], tag)
+				if end == -1 {
+					return nil, 0, "", fmt.Errorf("unterminated dollar quote %s at line %d", tag, line)
+				}
+				n = len(tag) + end + len(tag)
+			}
+			contentEnd = i + n
+		case !isSpace(rune(ch)):
+			markContent()
+			contentEnd = i + n
+		}
+
+		current.WriteString(rest[:n])
+		line += strings.Count(rest[:n], "\n")
+		i += n
+	}
+
+	unterminatedEnd := -1
+	if hasContent {
+		unterminatedEnd = contentEnd
+	}
+	flush()
+
+	return statements, unterminatedEnd, delimiter, nil
+}
+
+// execStatements splits the migration according to the database dialect and executes it one statement at a time.
+func execStatements(db iStatementDatabase, data []byte) error {
+	dialect, err := getSQLDialect(db.GetDialect())
+	if err != nil {
+		return err
+	}
+
+	statements, err := splitStatements(string(data), dialect)
+	if err != nil {
+		return err
+	}
+
+	for i := range statements {
+		if err := db.ExecuteStatement([]byte(statements[i].Text)); err != nil {
+			return &MigrationError{Statement: &statements[i], StatementIndex: i + 1, Err: err}
+		}
+	}
+
+	return nil
+}
+
+func isDelimiterCommand(s string) bool {
+	const command = "DELIMITER"
+	return len(s) > len(command) && strings.EqualFold(s[:len(command)], command) && (s[len(command)] == ' ' || s[len(command)] == '\t')
+}
+
+// indexClosingQuote returns the index of the quote closing the one at the beginning of s, or -1.
+// A doubled quote is an escaped quote.
+func indexClosingQuote(s string, quote byte, backslashEscapes bool) int {
+	for i := 1; i < len(s); i++ {
+		switch {
+		case backslashEscapes && s[i] == '\\':
+			i++
+		case s[i] == quote && i+1 < len(s) && s[i+1] == quote:
+			i++
+		case s[i] == quote:
+			return i
+		}
+	}
+	return -1
+}
+
+// isEscapeStringPrefix returns true when the text preceding a quote ends with the E prefix of an escape string (e.g. E'it\'s').
+func isEscapeStringPrefix(preceding string) bool {
+	n := len(preceding)
+	if n == 0 || (preceding[n-1] != 'E' && preceding[n-1] != 'e') {
+		return false
+	}
+	return n == 1 || !isIdentifierChar(preceding[n-2])
+}
+
+func isIdentifierChar(ch byte) bool {
+	return ch == '_' || (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z') || (ch >= '0' && ch <= '9')
+}
+
+// getDollarQuoteTag returns the dollar-quote tag (e.g. $$ or $body$) at the beginning of s, or an empty string.
+func getDollarQuoteTag(s string) string {
+	for i := 1; i < len(s); i++ {
+		ch := s[i]
+		switch {
+		case ch == '$':
+			return s[:i+1]
+		case ch == '_' || (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z') || (i > 1 && ch >= '0' && ch <= '9'):
+			continue
+		default:
+			return ""
+		}
+	}
+	return ""
+}
+
+func indexOrLen(s string, substr string) int {
+	if i := strings.Index(s, substr); i != -1 {
+		return i
+	}
+	return len(s)
+}
+
+func isSpace(r rune) bool {
+	return r == ' ' || r == '\t' || r == '\n' || r == '\r'
+}
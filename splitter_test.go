@@ -0,0 +1,174 @@
+package dbshiftcore
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestSplitStatements(t *testing.T) {
+	sql := `-- leading comment
+CREATE TABLE greetings (
+    description VARCHAR(150) NOT NULL -- inline comment; not a delimiter
+);
+
+/* block; comment */
+INSERT INTO greetings VALUES ('hello; world'), ('it''s');
+INSERT INTO greetings VALUES ("double; quoted")`
+
+	statements, err := splitStatements(sql, sqlDialects[""])
+	assert.Nil(t, err)
+	assert.Equal(t, []Statement{{
+		Text:      "CREATE TABLE greetings (\n    description VARCHAR(150) NOT NULL -- inline comment; not a delimiter\n)",
+		StartLine: 2,
+		EndLine:   4,
+	}, {
+		Text:      "INSERT INTO greetings VALUES ('hello; world'), ('it''s')",
+		StartLine: 7,
+		EndLine:   7,
+	}, {
+		Text:      `INSERT INTO greetings VALUES ("double; quoted")`,
+		StartLine: 8,
+		EndLine:   8,
+	}}, statements)
+}
+
+func TestSplitStatements_MySQL(t *testing.T) {
+	sql := "# hash; comment\n" +
+		"INSERT INTO `weird;table` VALUES ('escaped \\' quote;');\n" +
+		"DELIMITER //\n" +
+		"CREATE PROCEDURE hello()\n" +
+		"BEGIN\n" +
+		"  SELECT 1;\n" +
+		"  SELECT 2;\n" +
+		"END //\n" +
+		"DELIMITER ;\n" +
+		"SELECT 3;"
+
+	statements, err := splitStatements(sql, sqlDialects["mysql"])
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(statements))
+	assert.Equal(t, "INSERT INTO `weird;table` VALUES ('escaped \\' quote;')", statements[0].Text)
+	assert.Equal(t, "CREATE PROCEDURE hello()\nBEGIN\n  SELECT 1;\n  SELECT 2;\nEND", statements[1].Text)
+	assert.Equal(t, 4, statements[1].StartLine)
+	assert.Equal(t, 8, statements[1].EndLine)
+	assert.Equal(t, Statement{Text: "SELECT 3", StartLine: 10, EndLine: 10}, statements[2])
+}
+
+func TestSplitStatements_Postgres(t *testing.T) {
+	sql := `CREATE FUNCTION one() RETURNS integer AS $$
+BEGIN
+  RETURN 1;
+END;
+$$ LANGUAGE plpgsql;
+CREATE FUNCTION two() RETURNS text AS $body$ SELECT 'a;b' $body$ LANGUAGE sql;
+PREPARE q AS SELECT $1;`
+
+	statements, err := splitStatements(sql, sqlDialects["postgres"])
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(statements))
+	assert.True(t, strings.HasSuffix(statements[0].Text, "$$ LANGUAGE plpgsql"))
+	assert.Equal(t, "CREATE FUNCTION two() RETURNS text AS $body$ SELECT 'a;b' $body$ LANGUAGE sql", statements[1].Text)
+	assert.Equal(t, "PREPARE q AS SELECT $1", statements[2].Text)
+}
+
+func TestSplitStatements_PostgresEscapeStrings(t *testing.T) {
+	sql := "INSERT INTO greetings VALUES (E'it\\'s; here'), (e'tab\\t');\n" +
+		"INSERT INTO greetings VALUES ('no\\'), (name'');"
+
+	statements, err := splitStatements(sql, sqlDialects["postgres"])
+	assert.Nil(t, err)
+	assert.Len(t, statements, 2)
+	assert.Equal(t, []string{
+		"INSERT INTO greetings VALUES (E'it\\'s; here'), (e'tab\\t')",
+		"INSERT INTO greetings VALUES ('no\\'), (name'')",
+	}, []string{statements[0].Text, statements[1].Text})
+}
+
+func TestSplitStatements_Errors(t *testing.T) {
+	inputs := map[string]sqlDialect{
+		"SELECT 'unterminated;":      sqlDialects[""],
+		"SELECT 1; /* unterminated":  sqlDialects[""],
+		"SELECT $$ unterminated;":    sqlDialects["postgres"],
+		"DELIMITER \nSELECT 1;":      sqlDialects["mysql"],
+		"DELIMITER // extra\nSELECT": sqlDialects["mysql"],
+	}
+
+	for sql, dialect := range inputs {
+		_, err := splitStatements(sql, dialect)
+		assert.NotNil(t, err, "expected error splitting %q", sql)
+	}
+}
+
+func TestSplitStatements_Empty(t *testing.T) {
+	statements, err := splitStatements("  -- only comments;\n/* ; */ ;\n", sqlDialects[""])
+	assert.Nil(t, err)
+	assert.Empty(t, statements)
+}
+
+func TestTerminateStatements(t *testing.T) {
+	inputs := map[string]string{
+		"":                               "",
+		"-- nothing\n":                   "-- nothing\n",
+		"SELECT 1;\nSELECT 2;\n":         "SELECT 1;\nSELECT 2;\n",
+		"SELECT 1;\nSELECT 2\n":          "SELECT 1;\nSELECT 2;\n",
+		"SELECT 'a;b' -- done; really\n": "SELECT 'a;b'; -- done; really\n",
+	}
+	for sql, expected := range inputs {
+		terminated, err := terminateStatements(sql, sqlDialects[""])
+		assert.Nil(t, err)
+		assert.Equal(t, expected, terminated)
+	}
+
+	// The delimiter in effect terminates the statement
+	terminated, err := terminateStatements("DELIMITER $$\nCREATE PROCEDURE p() BEGIN SELECT 1; END\n", sqlDialects["mysql"])
+	assert.Nil(t, err)
+	assert.Equal(t, "DELIMITER $$\nCREATE PROCEDURE p() BEGIN SELECT 1; END$$\n", terminated)
+
+	_, err = terminateStatements("SELECT 'a", sqlDialects[""])
+	assert.NotNil(t, err, "expected error on unterminated quote")
+}
+
+func TestGetSQLDialect(t *testing.T) {
+	_, err := getSQLDialect("MySQL")
+	assert.Nil(t, err)
+
+	_, err = getSQLDialect("unknown")
+	assert.NotNil(t, err)
+}
+
+func TestExecStatements(t *testing.T) {
+	db := &statementDbImplementation{dialect: "mysql"}
+
+	err := execStatements(db, []byte("SELECT 1;\nSELECT 'FAIL';\nSELECT 3;"))
+	assert.NotNil(t, err)
+	migrationErr, ok := err.(*MigrationError)
+	assert.True(t, ok, "expected migration error")
+	assert.Equal(t, 2, migrationErr.StatementIndex)
+	assert.Equal(t, "SELECT 'FAIL'", migrationErr.Statement.Text)
+	assert.Equal(t, []string{"SELECT 1", "SELECT 'FAIL'"}, db.statements)
+
+	db.dialect = "unknown"
+	assert.NotNil(t, execStatements(db, []byte("SELECT 1;")))
+}
+
+// Helpers
+
+type statementDbImplementation struct {
+	dummyDbImplementation
+	dialect    string
+	statements []string
+}
+
+func (db *statementDbImplementation) GetDialect() string {
+	return db.dialect
+}
+
+func (db *statementDbImplementation) ExecuteStatement(statement []byte) error {
+	db.statements = append(db.statements, string(statement))
+	if strings.Contains(string(statement), "FAIL") {
+		return errors.New("failing statement")
+	}
+	return nil
+}
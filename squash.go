@@ -0,0 +1,183 @@
+package dbshiftcore
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+const (
+	flagFrom          = "from"
+	squashedFolder    = ".squashed"
+	baselineName      = "baseline"
+	baselineDowngrade = "-- dbshift:irreversible\n"
+)
+
+// iSchemaDatabase is an optional capability of the database implementation.
+// DumpSchema returns the statements creating the current schema of the database, used as baseline by squash.
+type iSchemaDatabase interface {
+	DumpSchema() ([]byte, error)
+}
+
+// squash replaces the migrations up to the version with a baseline migration sharing that version,
+// so databases already at or past it see a consistent status while new databases start from the baseline.
+// The baseline is read from the given file, otherwise it is dumped from the database, which must be exactly at the version.
+// Squashed files are moved into the .squashed folder of their location.
+// Databases part-way through the squashed migrations cannot be upgraded afterwards, see checkSquashedStatus.
+func (c *cmd) squash(upToVersion string, baselineLocation string) error {
+	migrationList, err := c.getSquashPlan(upToVersion)
+	if err != nil {
+		return err
+	}
+
+	baseline, err := c.getBaseline(upToVersion, baselineLocation)
+	if err != nil {
+		return err
+	}
+
+	// Move squashed files
+	for _, m := range migrationList {
+		location := m.getLocation(c.getMigrationsPath())
+		squashedLocation := filepath.Join(m.Dir, squashedFolder, m.Folder, m.Name)
+
+		if err := os.MkdirAll(filepath.Dir(squashedLocation), 0775); err != nil {
+			return err
+		}
+		if err := os.Rename(location, squashedLocation); err != nil {
+			return err
+		}
+	}
+
+	// Write baseline
+	dbExt := c.db.GetExtension()
+	downgrade := newMigration(upToVersion, baselineName, migrationTypeDowngrade, dbExt)
+	if err := writeNewFile(downgrade.getLocation(c.getMigrationsPath()), []byte(baselineDowngrade)); err != nil {
+		return err
+	}
+
+	upgrade := newMigration(upToVersion, baselineName, migrationTypeUpgrade, dbExt)
+	if err := writeNewFile(upgrade.getLocation(c.getMigrationsPath()), baseline); err != nil {
+		return err
+	}
+
+	// Keep manifests up to date
+	for _, location := range c.getMigrationsPaths() {
+		var removedList, addedList []Migration
+		for _, m := range migrationList {
+			if m.Dir == location {
+				removedList = append(removedList, m)
+			}
+		}
+		if location == c.getMigrationsPath() {
+			addedList = []Migration{downgrade, upgrade}
+		}
+		if err := updateManifest(location, removedList, addedList); err != nil {
+			return err
+		}
+	}
+
+	c.printSuccess("%d migrations have been squashed into %s", len(migrationList), upgrade.Name)
+	return nil
+}
+
+// getSquashPlan returns the migration files up to the version, checking that no remaining migration depends on them.
+func (c *cmd) getSquashPlan(upToVersion string) ([]Migration, error) {
+	// Check option
+	if c.cfg.getOptions().IsCreateDisabled {
+		return nil, c.newDisabledError("squashing")
+	}
+
+	var isFound bool
+	migrationList, err := c.getMigrations(Status{}, "", func(m Migration, status Status, toInclusiveVersion string) bool {
+		isFound = isFound || m.Version == upToVersion
+		return m.Version <= upToVersion
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if !isFound {
+		return nil, fmt.Errorf("migration version %s not found", upToVersion)
+	}
+
+	// The baseline keeps the version, so only dependencies on older versions are lost
+	graph, err := c.getDependencyGraph()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, version := range getGraphVersions(graph) {
+		m := graph[version]
+		if m.Version <= upToVersion {
+			continue
+		}
+		for _, dependency := range m.Directives.Dependencies {
+			if dependency < upToVersion {
+				return nil, &DependencyError{Migration: m, Reason: fmt.Sprintf("dependency %s would be squashed", dependency)}
+			}
+		}
+	}
+
+	return migrationList, nil
+}
+
+// checkSquashedStatus returns an error when the status is the version of a squashed migration replaced by a newer baseline:
+// the database is part-way through the squashed migrations and upgrading it would run the whole baseline.
+func (c *cmd) checkSquashedStatus(status Status) error {
+	if status.Version == "" {
+		return nil
+	}
+
+	var isSquashed bool
+	for _, location := range c.getMigrationsPaths() {
+		err := filepath.Walk(filepath.Join(location, squashedFolder), func(path string, info os.FileInfo, err error) error {
+			if info == nil || info.IsDir() {
+				return nil
+			}
+			if m, err := newMigrationFromFile(info.Name(), 0); err == nil && m.Version == status.Version {
+				isSquashed = true
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if !isSquashed {
+		return nil
+	}
+
+	// The baseline shares the version of the last squashed migration
+	baselineList, err := c.getMigrations(Status{}, "", func(m Migration, _ Status, _ string) bool {
+		return m.Type == migrationTypeUpgrade && m.Version == status.Version
+	})
+	if err != nil || len(baselineList) > 0 {
+		return err
+	}
+
+	return fmt.Errorf("the status %s is the version of a squashed migration: the database must be upgraded with the squashed migrations before using the baseline", status.Version)
+}
+
+// getBaseline returns the content of the baseline migration.
+func (c *cmd) getBaseline(upToVersion string, baselineLocation string) ([]byte, error) {
+	if baselineLocation != "" {
+		return ioutil.ReadFile(baselineLocation)
+	}
+
+	schemaDb, ok := c.db.(iSchemaDatabase)
+	if !ok {
+		return nil, fmt.Errorf("schema dump is not supported by the database implementation: use --%s <file>", flagFrom)
+	}
+
+	// The schema must be the one of the squashed migrations
+	status, err := c.db.GetStatus()
+	if err != nil {
+		return nil, err
+	}
+	if status.Version != upToVersion || status.Type != migrationTypeUpgrade {
+		return nil, fmt.Errorf("database must be upgraded exactly to %s in order to dump its schema: use --%s <file> otherwise", upToVersion, flagFrom)
+	}
+
+	return schemaDb.DumpSchema()
+}
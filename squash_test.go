@@ -0,0 +1,86 @@
+package dbshiftcore
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCmd_Squash(t *testing.T) {
+	err := setConfigurationWithCustomOptions("false", "false", "false")
+	assert.Nil(t, err, "expected nil error on set configuration with custom options")
+	defer unsetConfiguration(t, "DBSHIFT_DUMMY_SQUASH_STATUS")
+
+	db := &schemaDbImplementation{dummyDbImplementation: dummyDbImplementation{envStatus: "DBSHIFT_DUMMY_SQUASH_STATUS"}}
+	squashCmd, err := NewCmd(db)
+	assert.Nil(t, err, "expected nil error")
+
+	migrationsPath := squashCmd.getMigrationsPath()
+	writeMigrationFiles(t, migrationsPath, "20200101000000", "users")
+	writeMigrationFiles(t, migrationsPath, "20200102000000", "orders")
+	writeMigrationFiles(t, migrationsPath, "20200103000000", "invoices")
+
+	_, err = squashCmd.getSquashPlan("20200104000000")
+	assert.NotNil(t, err, "expected error on unknown version")
+
+	migrationList, err := squashCmd.getSquashPlan("20200102000000")
+	assert.Nil(t, err)
+	assert.Len(t, migrationList, 4)
+
+	// The schema can be dumped only at the squashed version
+	assert.NotNil(t, squashCmd.squash("20200102000000", ""), "expected error on database at another version")
+	assert.Nil(t, squashCmd.upgrade("20200102000000"))
+	assert.Nil(t, squashCmd.squash("20200102000000", ""))
+
+	_, err = os.Stat(filepath.Join(migrationsPath, squashedFolder, "20200101000000-users.up.txt"))
+	assert.Nil(t, err, "expected squashed file to be moved")
+
+	baseline := newMigration("20200102000000", baselineName, migrationTypeUpgrade, "txt")
+	data, err := ioutil.ReadFile(baseline.getLocation(migrationsPath))
+	assert.Nil(t, err)
+	assert.Equal(t, "CREATE TABLE users (); CREATE TABLE orders ();", string(data))
+
+	// Databases past the baseline are consistent
+	migrationList, err = squashCmd.getUpgradePlan("")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"20200103000000"}, getVersions(migrationList))
+
+	// The baseline cannot be downgraded
+	_, err = squashCmd.getDowngradePlan("", false)
+	assert.IsType(t, &IrreversibleError{}, err)
+
+	// New databases start from the baseline
+	assert.Nil(t, os.Unsetenv(db.envStatus))
+	db.history = nil
+	migrationList, err = squashCmd.getUpgradePlan("")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"20200102000000", "20200103000000"}, getVersions(migrationList))
+	assert.Equal(t, baseline.Name, migrationList[0].Name)
+
+	// Databases part-way through the squashed migrations are reported
+	assert.Nil(t, db.SetStatus(Migration{Version: "20200101000000", Type: migrationTypeUpgrade}, 0))
+	_, err = squashCmd.getUpgradePlan("")
+	assert.NotNil(t, err, "expected error on database at a squashed version")
+	assert.Nil(t, squashCmd.status())
+	assert.Nil(t, os.Unsetenv(db.envStatus))
+
+	// A baseline can be supplied without the database capability
+	baselineLocation := filepath.Join(migrationsPath, squashedFolder, "baseline.txt")
+	assert.Nil(t, ioutil.WriteFile(baselineLocation, []byte("CREATE SCHEMA app;"), 0664))
+	plainCmd, err := NewCmd(&dummyDbImplementation{envStatus: "DBSHIFT_DUMMY_SQUASH_STATUS"})
+	assert.Nil(t, err, "expected nil error")
+	assert.NotNil(t, plainCmd.squash("20200103000000", ""), "expected error without schema dump")
+	assert.Nil(t, plainCmd.squash("20200103000000", baselineLocation))
+}
+
+// Helpers
+
+type schemaDbImplementation struct {
+	dummyDbImplementation
+}
+
+func (db *schemaDbImplementation) DumpSchema() ([]byte, error) {
+	return []byte("CREATE TABLE users (); CREATE TABLE orders ();"), nil
+}
@@ -0,0 +1,68 @@
+package dbshiftcore
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"text/template"
+)
+
+// renderMigration executes the migration as a text/template (e.g. {{.schema}}) with the given variables.
+// An undefined variable is an error.
+func renderMigration(name string, data []byte, variables map[string]string) ([]byte, error) {
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("bad template %s: %s", name, err)
+	}
+
+	if variables == nil {
+		variables = map[string]string{}
+	}
+
+	var rendered bytes.Buffer
+	if err := tmpl.Execute(&rendered, variables); err != nil {
+		return nil, fmt.Errorf("bad template %s: %s", name, err)
+	}
+
+	return rendered.Bytes(), nil
+}
+
+// readMigration reads the migration file, rendering it when templating is enabled.
+func (c *cmd) readMigration(m Migration) ([]byte, error) {
+	data, err := ioutil.ReadFile(m.getLocation(c.getMigrationsPath()))
+	if err != nil {
+		return nil, err
+	}
+
+	if !c.cfg.getOptions().IsTemplatingEnabled {
+		return data, nil
+	}
+
+	return renderMigration(m.Name, data, c.cfg.Variables)
+}
+
+// render prints the final content of the migrations with the given version.
+func (c *cmd) render(version string) error {
+	migrationList, err := c.getMigrations(Status{}, "", func(m Migration, status Status, toInclusiveVersion string) bool {
+		return m.Version == version
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(migrationList) == 0 {
+		return fmt.Errorf("migration %s does not exist", version)
+	}
+
+	sort.Sort(upgradePerspective(migrationList))
+	for _, m := range migrationList {
+		data, err := c.readMigration(m)
+		if err != nil {
+			return err
+		}
+		c.printf("-- %s\n%s\n", m.Name, data)
+	}
+
+	return nil
+}
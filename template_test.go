@@ -0,0 +1,62 @@
+package dbshiftcore
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestRenderMigration(t *testing.T) {
+	variables := map[string]string{"schema": "app", "role": "app_rw"}
+
+	data, err := renderMigration("test", []byte("GRANT SELECT ON {{.schema}}.greetings TO {{.role}};"), variables)
+	assert.Nil(t, err)
+	assert.Equal(t, "GRANT SELECT ON app.greetings TO app_rw;", string(data))
+
+	_, err = renderMigration("test", []byte("CREATE TABLE {{.shcema}}.greetings;"), variables)
+	assert.NotNil(t, err, "expected error on undefined variable")
+
+	_, err = renderMigration("test", []byte("CREATE TABLE {{.schema}}.greetings;"), nil)
+	assert.NotNil(t, err, "expected error on undefined variable without variables")
+
+	_, err = renderMigration("test", []byte("CREATE TABLE {{.schema"), variables)
+	assert.NotNil(t, err, "expected error on bad template")
+}
+
+func TestCmd_ReadMigration(t *testing.T) {
+	err := setConfigurationWithCustomOptions("false", "false", "false")
+	assert.Nil(t, err, "expected nil error on set configuration with custom options")
+	defer unsetConfiguration(t, "DBSHIFT_DUMMY_TEMPLATE_STATUS")
+
+	err = os.Setenv(envPrefixVariable+"schema", "app")
+	assert.Nil(t, err)
+	defer os.Unsetenv(envPrefixVariable + "schema")
+
+	templateCmd, err := NewCmdWithConfiguration(new(dummyDbImplementation), Configuration{
+		Options:   ConfigurationOptions{IsTemplatingEnabled: true},
+		Variables: map[string]string{"schema": "overridden", "role": "app_rw"},
+	})
+	assert.Nil(t, err, "expected nil error")
+	assert.Equal(t, "env (DBSHIFT_VAR_schema)", templateCmd.sources.get("variables.schema"))
+	assert.Equal(t, configurationSourceCode, templateCmd.sources.get("variables.role"))
+
+	migrationsPath := os.Getenv(envPathMigrations)
+	writeMigrationFiles(t, migrationsPath, "20200101000000", "template")
+	m := newMigration("20200101000000", "template", migrationTypeUpgrade, "txt")
+	err = ioutil.WriteFile(m.getLocation(migrationsPath), []byte("GRANT SELECT ON {{.schema}}.t TO {{.role}};"), 0664)
+	assert.Nil(t, err)
+
+	data, err := templateCmd.readMigration(m)
+	assert.Nil(t, err)
+	assert.Equal(t, "GRANT SELECT ON app.t TO app_rw;", string(data))
+
+	assert.Nil(t, templateCmd.render("20200101000000"))
+	assert.NotNil(t, templateCmd.render("20200102000000"), "expected error on unexisting migration")
+
+	// Templating disabled
+	templateCmd.cfg.Options.IsTemplatingEnabled = false
+	data, err = templateCmd.readMigration(m)
+	assert.Nil(t, err)
+	assert.Equal(t, "GRANT SELECT ON {{.schema}}.t TO {{.role}};", string(data))
+}
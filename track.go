@@ -0,0 +1,112 @@
+package dbshiftcore
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// iTrackDatabase is an optional capability of the database implementation, required by migration tracks.
+// SetTrack selects the track of the following status and history calls, an empty track is the main one.
+type iTrackDatabase interface {
+	SetTrack(track string) error
+}
+
+// getTrackNames returns the main track (empty name) followed by the named tracks sorted by name.
+func (cfg Configuration) getTrackNames() []string {
+	names := []string{""}
+	for name := range cfg.Tracks {
+		names = append(names, name)
+	}
+	sort.Strings(names[1:])
+	return names
+}
+
+// getTrackPaths returns the migrations locations of the track.
+// Track locations are relative to the first location of the main track unless absolute.
+func (cfg Configuration) getTrackPaths(track string) []string {
+	mainPaths := splitMigrationsPaths(cfg.MigrationsPath)
+	if track == "" {
+		return mainPaths
+	}
+
+	var paths []string
+	for _, path := range splitMigrationsPaths(cfg.Tracks[track]) {
+		if !filepath.IsAbs(path) && len(mainPaths) > 0 {
+			path = filepath.Join(mainPaths[0], path)
+		}
+		paths = append(paths, filepath.Clean(path))
+	}
+	return paths
+}
+
+// getOtherTrackPaths returns the locations of the other tracks nested in the location, along with their track name.
+func (c *cmd) getOtherTrackPaths(location string) map[string]string {
+	paths := map[string]string{}
+	for _, track := range c.cfg.getTrackNames() {
+		for _, trackPath := range c.cfg.getTrackPaths(track) {
+			// Tracks containing the location (e.g. the main track) are not excluded
+			if !isSubPath(location, trackPath) && isSubPath(trackPath, location) {
+				paths[trackPath] = track
+			}
+		}
+	}
+	return paths
+}
+
+// isSubPath returns true when the path is the parent path or one of its descendants.
+func isSubPath(path string, parentPath string) bool {
+	return path == parentPath || strings.HasPrefix(path, parentPath+string(filepath.Separator))
+}
+
+// useTrack selects the track of the following commands.
+func (c *cmd) useTrack(track string) error {
+	if _, ok := c.cfg.Tracks[track]; track != "" && !ok {
+		return fmt.Errorf("track %s is not defined", track)
+	}
+
+	trackDb, ok := c.db.(iTrackDatabase)
+	if !ok {
+		if len(c.cfg.Tracks) > 0 {
+			return errors.New("tracks are not supported by the database implementation")
+		}
+		return nil
+	}
+
+	if err := trackDb.SetTrack(track); err != nil {
+		return err
+	}
+
+	c.cfg.Track = track
+	return nil
+}
+
+// tracks prints the status of every track, then it selects the active track again.
+func (c *cmd) tracks() (err error) {
+	activeTrack := c.cfg.Track
+	defer func() {
+		if trackErr := c.useTrack(activeTrack); err == nil {
+			err = trackErr
+		}
+	}()
+
+	for _, track := range c.cfg.getTrackNames() {
+		if err := c.useTrack(track); err != nil {
+			return err
+		}
+
+		name := track
+		if name == "" {
+			name = "main"
+		}
+		c.printf("Track: %s (%s)\n", name, strings.Join(c.getMigrationsPaths(), string(filepath.ListSeparator)))
+
+		if err := c.status(); err != nil {
+			return fmt.Errorf("track %s: %s", name, err)
+		}
+	}
+
+	return nil
+}
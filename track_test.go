@@ -0,0 +1,80 @@
+package dbshiftcore
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestConfiguration_GetTrackPath(t *testing.T) {
+	cfg := Configuration{
+		MigrationsPath: "/migrations",
+		Tracks:         map[string]string{"billing": "billing", "auth": "/vendor/auth/"},
+	}
+
+	assert.Equal(t, []string{"", "auth", "billing"}, cfg.getTrackNames())
+	assert.Equal(t, []string{"/migrations"}, cfg.getTrackPaths(""))
+	assert.Equal(t, []string{"/migrations/billing"}, cfg.getTrackPaths("billing"))
+	assert.Equal(t, []string{"/vendor/auth"}, cfg.getTrackPaths("auth"))
+}
+
+func TestCmd_Tracks(t *testing.T) {
+	err := setConfigurationWithCustomOptions("false", "false", "false")
+	assert.Nil(t, err, "expected nil error on set configuration with custom options")
+	defer unsetConfiguration(t, "DBSHIFT_DUMMY_TRACK_STATUS")
+	defer unsetConfiguration(t, "DBSHIFT_DUMMY_TRACK_STATUS_BILLING")
+
+	migrationsPath := os.Getenv(envPathMigrations)
+	billingPath := filepath.Join(migrationsPath, "billing")
+	assert.Nil(t, os.MkdirAll(billingPath, 0775))
+	defer os.RemoveAll(billingPath)
+
+	writeMigrationFiles(t, migrationsPath, "20200101000000", "users")
+	writeMigrationFiles(t, billingPath, "20200102000000", "invoices")
+
+	cfg := Configuration{Tracks: map[string]string{"billing": "billing"}}
+
+	// Tracks require the database capability
+	_, err = NewCmdWithConfiguration(&dummyDbImplementation{envStatus: "DBSHIFT_DUMMY_TRACK_STATUS"}, cfg)
+	assert.NotNil(t, err, "expected error without track capability")
+
+	db := &trackDbImplementation{dummyDbImplementation: dummyDbImplementation{envStatus: "DBSHIFT_DUMMY_TRACK_STATUS"}}
+	trackCmd, err := NewCmdWithConfiguration(db, cfg)
+	assert.Nil(t, err, "expected nil error")
+
+	// The main track excludes the folders of the other tracks
+	migrationList, err := trackCmd.getUpgradePlan("")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"20200101000000"}, getVersions(migrationList))
+	assert.Nil(t, trackCmd.execMigrations(migrationList))
+
+	// Every track has its own status
+	assert.Nil(t, trackCmd.useTrack("billing"))
+	assert.Equal(t, "billing", db.track)
+	migrationList, err = trackCmd.getUpgradePlan("")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"20200102000000"}, getVersions(migrationList))
+
+	assert.Nil(t, trackCmd.tracks())
+	assert.Equal(t, "billing", db.track)
+
+	assert.NotNil(t, trackCmd.useTrack("search"), "expected error on undefined track")
+}
+
+// Helpers
+
+type trackDbImplementation struct {
+	dummyDbImplementation
+	track string
+}
+
+func (db *trackDbImplementation) SetTrack(track string) error {
+	db.envStatus = strings.TrimSuffix(db.envStatus, "_"+strings.ToUpper(db.track))
+	if track != "" {
+		db.envStatus += "_" + strings.ToUpper(track)
+	}
+	db.track = track
+	return nil
+}
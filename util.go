@@ -2,6 +2,8 @@ package dbshiftcore
 
 import (
 	"fmt"
+	"io"
+	"os"
 )
 
 const successCharacter rune = '✔'
@@ -9,16 +11,39 @@ const failureCharacter rune = '✘'
 
 // PrintSuccess prints a formatted text adding a special success character
 func PrintSuccess(text string, args ...interface{}) {
+	fprintSuccess(os.Stdout, text, args...)
+}
+
+// PrintFailure prints a formatted text adding a special failure character
+func PrintFailure(text string, args ...interface{}) {
+	fprintFailure(os.Stdout, text, args...)
+}
+
+func fprintSuccess(w io.Writer, text string, args ...interface{}) {
 	if len(args) > 0 {
 		text = fmt.Sprintf(text, args...)
 	}
-	fmt.Printf("%c %s\n", successCharacter, text)
+	fmt.Fprintf(w, "%c %s\n", successCharacter, text)
 }
 
-// PrintFailure prints a formatted text adding a special failure character
-func PrintFailure(text string, args ...interface{}) {
+func fprintFailure(w io.Writer, text string, args ...interface{}) {
 	if len(args) > 0 {
 		text = fmt.Sprintf(text, args...)
 	}
-	fmt.Printf("%c %s\n", failureCharacter, text)
+	fmt.Fprintf(w, "%c %s\n", failureCharacter, text)
+}
+
+// printf prints to the output of the shell-commander.
+func (c *cmd) printf(format string, args ...interface{}) {
+	fmt.Fprintf(c.out, format, args...)
+}
+
+// printSuccess prints a success to the output of the shell-commander.
+func (c *cmd) printSuccess(text string, args ...interface{}) {
+	fprintSuccess(c.out, text, args...)
+}
+
+// printFailure prints a failure to the error output of the shell-commander.
+func (c *cmd) printFailure(text string, args ...interface{}) {
+	fprintFailure(c.errOut, text, args...)
 }
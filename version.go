@@ -0,0 +1,46 @@
+package dbshiftcore
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+const versionLayout = "20060102150405"
+
+// getNextVersion returns the version of a new migration: the current UTC time,
+// bumped by one second until no migration of the active track uses it.
+func (c *cmd) getNextVersion() (string, error) {
+	versions := map[string]bool{}
+	_, err := c.getMigrations(Status{}, "", func(m Migration, status Status, toInclusiveVersion string) bool {
+		versions[m.Version] = true
+		return false
+	})
+	if err != nil {
+		return "", err
+	}
+
+	t := c.clock().UTC()
+	for versions[t.Format(versionLayout)] {
+		t = t.Add(time.Second)
+	}
+
+	return t.Format(versionLayout), nil
+}
+
+// writeNewFile writes the file, refusing to overwrite an existing one.
+func writeNewFile(location string, data []byte) error {
+	f, err := os.OpenFile(location, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0664)
+	if os.IsExist(err) {
+		return fmt.Errorf("file %s already exists", location)
+	}
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,50 @@
+package dbshiftcore
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWriteNewFile(t *testing.T) {
+	location := filepath.Join(os.TempDir(), "dbshift-new-file.txt")
+	defer os.Remove(location)
+	_ = os.Remove(location)
+
+	assert.Nil(t, writeNewFile(location, []byte("SELECT 1;")))
+	assert.NotNil(t, writeNewFile(location, []byte("SELECT 2;")), "expected error on existing file")
+
+	data, err := ioutil.ReadFile(location)
+	assert.Nil(t, err)
+	assert.Equal(t, "SELECT 1;", string(data))
+}
+
+func TestCmd_CreateVersion(t *testing.T) {
+	err := setConfigurationWithCustomOptions("false", "false", "false")
+	assert.Nil(t, err, "expected nil error on set configuration with custom options")
+	defer unsetConfiguration(t, "DBSHIFT_DUMMY_VERSION_STATUS")
+
+	versionCmd, err := NewCmd(&dummyDbImplementation{envStatus: "DBSHIFT_DUMMY_VERSION_STATUS"})
+	assert.Nil(t, err, "expected nil error")
+
+	// Versions are in UTC whatever the local time zone
+	versionCmd.clock = func() time.Time {
+		return time.Date(2020, time.January, 1, 10, 0, 0, 0, time.FixedZone("CET", 3600))
+	}
+
+	version, err := versionCmd.getNextVersion()
+	assert.Nil(t, err)
+	assert.Equal(t, "20200101090000", version)
+
+	// Migrations created within the same second do not collide
+	assert.Nil(t, versionCmd.create("users", ""))
+	assert.Nil(t, versionCmd.create("users", ""))
+	assert.Nil(t, versionCmd.create("orders", ""))
+
+	migrationList, err := versionCmd.getUpgradePlan("")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"20200101090000", "20200101090001", "20200101090002"}, getVersions(migrationList))
+}